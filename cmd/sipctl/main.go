@@ -0,0 +1,190 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// sipctl simulates and inspects SIP scheduling outside of the operator.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+)
+
+var scheme = runtime.NewScheme()
+
+// stdout is where the commands print their results. The debug output of the scheduler is kept out of it, so that the
+// results can be piped and parsed.
+var stdout io.Writer = os.Stdout
+
+//nolint:errcheck
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = airshipv1.AddToScheme(scheme)
+	_ = metal3.AddToScheme(scheme)
+}
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{
+		name:        "schedule",
+		description: "Simulate the placement of a SIPCluster on a BareMetalHost inventory",
+		run:         runSchedule,
+	},
+	{
+		name:        "render",
+		description: "Print the infrastructure service manifests of a SIPCluster",
+		run:         runRender,
+	},
+	{
+		name:        "status",
+		description: "Summarize the hosts and services of a SIPCluster in the cluster",
+		run:         runStatus,
+	},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var verbose bool
+	flag.BoolVar(&verbose, "v", false, "Log the scheduler and service decisions to stderr.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if verbose {
+		ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+		bmh.DebugOutput = os.Stderr
+	} else {
+		ctrl.SetLogger(logr.Discard())
+		bmh.DebugOutput = ioutil.Discard
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != flag.Arg(0) {
+			continue
+		}
+		if err := cmd.run(flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
+
+// newClient returns a client for the cluster selected by the --kubeconfig flag, the KUBECONFIG environment variable
+// or the in-cluster configuration.
+func newClient() (client.Client, error) {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// newInventory returns a file-based inventory when dir is set, and an inventory of the live cluster otherwise.
+func newInventory(dir string) (bmh.Inventory, error) {
+	if dir != "" {
+		return bmh.NewFileInventory(dir)
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return bmh.NewKubernetesInventory(c), nil
+}
+
+// readSIPCluster reads a SIPCluster from a YAML file. No admission webhooks default and validate a SIPCluster that is
+// read from a file, so it is defaulted and validated here, and the fields that the validating webhook would reject
+// are listed in the error.
+func readSIPCluster(path string) (*airshipv1.SIPCluster, error) {
+	if path == "" {
+		return nil, fmt.Errorf("a SIPCluster file must be provided with -f")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	sip := &airshipv1.SIPCluster{}
+	if err = yaml.UnmarshalStrict(data, sip); err != nil {
+		return nil, fmt.Errorf("unable to parse SIPCluster %s: %w", path, err)
+	}
+	if sip.Namespace == "" {
+		sip.Namespace = "default"
+	}
+	sip.Default()
+	if err = sip.ValidateCreate(); err != nil {
+		return nil, invalidSIPCluster(path, err)
+	}
+	return sip, nil
+}
+
+// invalidSIPCluster returns an error that lists the field errors of a SIPCluster that failed validation.
+func invalidSIPCluster(path string, err error) error {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return fmt.Errorf("invalid SIPCluster %s: %w", path, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid SIPCluster %s:", path)
+	for _, cause := range status.Status().Details.Causes {
+		fmt.Fprintf(&sb, "\n  %s: %s", cause.Field, cause.Message)
+	}
+	return errors.New(sb.String())
+}
@@ -0,0 +1,195 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+	airshipsvc "sipcluster/pkg/services"
+)
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	sipFile := fs.String("f", "", "Path to the SIPCluster YAML to render.")
+	inventoryDir := fs.String("inventory", "",
+		"Directory of BareMetalHost, Secret and ConfigMap YAML to render from. "+
+			"The live cluster is used when not set.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sip, err := readSIPCluster(*sipFile)
+	if err != nil {
+		return err
+	}
+	inventory, err := newInventory(*inventoryDir)
+	if err != nil {
+		return err
+	}
+
+	var source client.Reader
+	if *inventoryDir != "" {
+		objs, err := readManifests(*inventoryDir)
+		if err != nil {
+			return err
+		}
+		// The manifests are served through an in-memory client, the same way the live cluster serves them
+		source = fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+	} else {
+		if source, err = newClient(); err != nil {
+			return err
+		}
+	}
+
+	objs, err := render(sip, inventory, source)
+	if err != nil {
+		return err
+	}
+	return printManifests(stdout, objs)
+}
+
+// render schedules a SIPCluster on the inventory and returns the objects of its infrastructure services. Node ports
+// are allocated against the Services that source holds.
+func render(sip *airshipv1.SIPCluster, inventory bmh.Inventory, source client.Reader) ([]client.Object, error) {
+	machines, err := schedule(*sip, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = airshipsvc.AllocateNodePorts(context.Background(), sip, source); err != nil {
+		return nil, err
+	}
+	inputs, err := airshipsvc.LoadRenderInputs(*sip, source)
+	if err != nil {
+		return nil, err
+	}
+	return airshipsvc.Render(ctrl.Log.WithName("services"), *sip, machines, inputs)
+}
+
+// readManifests decodes the objects of every YAML or JSON file in dir. Objects of kinds that are not known to sipctl
+// are skipped.
+func readManifests(dir string) ([]runtime.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	objs := []runtime.Object{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+
+			obj, _, err := decoder.Decode(doc, nil, nil)
+			if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to decode %s: %w", path, err)
+			}
+			if secret, ok := obj.(*corev1.Secret); ok {
+				mergeStringData(secret)
+			}
+			objs = append(objs, obj)
+		}
+	})
+	return objs, err
+}
+
+// mergeStringData merges the stringData of a Secret into its data, as the API server does.
+func mergeStringData(secret *corev1.Secret) {
+	if len(secret.StringData) == 0 {
+		return
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+	secret.StringData = nil
+}
+
+// printManifests prints objects as a multi-document YAML stream. Secret data is printed as stringData, so that
+// rendered configuration such as haproxy.cfg is readable.
+func printManifests(out io.Writer, objs []client.Object) error {
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		obj.SetResourceVersion("")
+
+		if secret, ok := obj.(*corev1.Secret); ok {
+			secret.StringData = make(map[string]string, len(secret.Data))
+			for k, v := range secret.Data {
+				secret.StringData[k] = string(v)
+			}
+			secret.Data = nil
+		}
+
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(out, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+)
+
+func runSchedule(args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	sipFile := fs.String("f", "", "Path to the SIPCluster YAML to schedule.")
+	inventoryDir := fs.String("inventory", "",
+		"Directory of BareMetalHost and Secret YAML to schedule from. The live cluster is used when not set.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sip, err := readSIPCluster(*sipFile)
+	if err != nil {
+		return err
+	}
+	inventory, err := newInventory(*inventoryDir)
+	if err != nil {
+		return err
+	}
+
+	// A partial placement is still printed, since it shows how far the inventory is from fitting the SIPCluster.
+	machines, scheduleErr := schedule(*sip, inventory)
+	printMachines(stdout, *sip, machines)
+	return scheduleErr
+}
+
+// schedule selects the BMHs for a SIPCluster in the same way the operator does, without labeling them.
+func schedule(sip airshipv1.SIPCluster, inventory bmh.Inventory) (*bmh.MachineList, error) {
+	machines := &bmh.MachineList{
+		NamespacedName: types.NamespacedName{
+			Name:      sip.Name,
+			Namespace: sip.Namespace,
+		},
+		Log: ctrl.Log.WithName("machines"),
+	}
+
+	for {
+		if err := machines.Schedule(sip, inventory); err != nil {
+			return machines, err
+		}
+
+		// Hosts that are missing addresses or BMC credentials are marked as UnableToSchedule, and are replaced by
+		// the next scheduling pass.
+		if err := machines.ExtrapolateServiceAddresses(sip, inventory); err != nil {
+			continue
+		}
+		if err := machines.ExtrapolateBMCAuth(sip, inventory); err != nil {
+			continue
+		}
+
+		return machines, nil
+	}
+}
+
+func printMachines(out io.Writer, sip airshipv1.SIPCluster, machines *bmh.MachineList) {
+	names := make([]string, 0, len(machines.Machines))
+	for name := range machines.Machines {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		mi, mj := machines.Machines[names[i]], machines.Machines[names[j]]
+		if mi.BMHRole != mj.BMHRole {
+			return mi.BMHRole < mj.BMHRole
+		}
+		return names[i] < names[j]
+	})
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BMH\tROLE\tSTATUS\tADDRESSES")
+	for _, name := range names {
+		machine := machines.Machines[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, machine.BMHRole, machine.ScheduleStatus,
+			formatAddresses(machine.Data.IPOnInterface))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "ROLE\tSELECTED\tREQUESTED")
+	roles := make([]string, 0, len(sip.Spec.Nodes))
+	for role := range sip.Spec.Nodes {
+		roles = append(roles, string(role))
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		nodeSet := sip.Spec.Nodes[airshipv1.BMHRole(role)]
+		requested := 0
+		if nodeSet.Count != nil {
+			requested = nodeSet.Count.Active + nodeSet.Count.Standby
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\n", role, machines.ReadyForScheduleCount[airshipv1.BMHRole(role)], requested)
+	}
+	w.Flush() //nolint:errcheck
+}
+
+func formatAddresses(ipOnInterface map[string]string) string {
+	if len(ipOnInterface) == 0 {
+		return "<none>"
+	}
+
+	addresses := make([]string, 0, len(ipOnInterface))
+	for iface, ip := range ipOnInterface {
+		addresses = append(addresses, iface+"="+ip)
+	}
+	sort.Strings(addresses)
+	return strings.Join(addresses, ",")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+	airshipsvc "sipcluster/pkg/services"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("sipctl", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sipctl")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	// writeYAML writes the objects as a single multi-document YAML file in dir.
+	writeYAML := func(name string, objs ...interface{}) string {
+		var content []byte
+		for _, obj := range objs {
+			doc, err := yaml.Marshal(obj)
+			Expect(err).NotTo(HaveOccurred())
+			content = append(content, []byte("---\n")...)
+			content = append(content, doc...)
+		}
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, content, 0600)).To(Succeed())
+		return path
+	}
+
+	// writeInventory writes two control plane and two worker hosts with their Secrets, and the control plane load
+	// balancer template, to the inventory directory. It returns the path of a SIPCluster file that leaves the defaulted
+	// fields out.
+	writeInventory := func() string {
+		Expect(os.Mkdir(filepath.Join(dir, "inventory"), 0700)).To(Succeed())
+		for node := 0; node < 4; node++ {
+			role := airshipv1.RoleControlPlane
+			if node%2 == 1 {
+				role = airshipv1.RoleWorker
+			}
+			host, networkData := testutil.CreateBMH(node, "default", role, 6)
+			host.TypeMeta = metav1.TypeMeta{APIVersion: metal3.GroupVersion.String(), Kind: "BareMetalHost"}
+			networkData.TypeMeta = metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"}
+			bmcSecret := testutil.CreateBMCAuthSecret(host.Name, host.Namespace, "root", "test")
+			host.Spec.BMC.CredentialsName = bmcSecret.Name
+			writeYAML(filepath.Join("inventory", host.Name+".yaml"), host, networkData, bmcSecret)
+		}
+
+		sip, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", "default", 2, 2)
+		nodeSSHPrivateKeys.TypeMeta = metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"}
+		writeYAML(filepath.Join("inventory", "ssh-keys.yaml"), nodeSSHPrivateKeys)
+		template, err := ioutil.ReadFile("../../config/manager/loadbalancer/loadBalancerControlPlane.cfg")
+		Expect(err).NotTo(HaveOccurred())
+		writeYAML(filepath.Join("inventory", "templates.yaml"), testutil.CreateTemplateConfigMap(
+			airshipsvc.ControlPlaneTemplateConfigMapName, "loadBalancerControlPlane.cfg", "default", string(template)))
+
+		for role, nodeSet := range sip.Spec.Nodes {
+			nodeSet.TopologyKey = ""
+			sip.Spec.Nodes[role] = nodeSet
+		}
+		sip.Spec.Services.JumpHost[0].Image = ""
+		sip.Namespace = ""
+		return writeYAML("sipcluster.yaml", sip)
+	}
+
+	It("Should apply defaults to a SIPCluster read from a file", func() {
+		sip, err := readSIPCluster(writeInventory())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sip.Namespace).To(Equal("default"))
+		Expect(sip.Spec.Nodes[airshipv1.RoleControlPlane].TopologyKey).To(Equal(airshipv1.DefaultTopologyKey))
+		Expect(sip.Spec.Services.JumpHost[0].Image).To(Equal(airshipv1.DefaultJumpHostImage))
+	})
+
+	It("Should list the invalid fields of a SIPCluster read from a file", func() {
+		sip, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		nodeSet := sip.Spec.Nodes[airshipv1.RoleWorker]
+		nodeSet.Count = nil
+		sip.Spec.Nodes[airshipv1.RoleWorker] = nodeSet
+
+		_, err := readSIPCluster(writeYAML("sipcluster.yaml", sip))
+		Expect(err).To(MatchError(ContainSubstring("\n  spec.nodes[Worker].count: Required value")))
+	})
+
+	It("Should simulate the schedule of a SIPCluster over a file inventory", func() {
+		sip, err := readSIPCluster(writeInventory())
+		Expect(err).NotTo(HaveOccurred())
+		inventory, err := newInventory(filepath.Join(dir, "inventory"))
+		Expect(err).NotTo(HaveOccurred())
+
+		machines, err := schedule(*sip, inventory)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machines.Machines).To(HaveLen(4))
+
+		out := &bytes.Buffer{}
+		printMachines(out, *sip, machines)
+		for node := 0; node < 4; node++ {
+			Expect(out.String()).To(ContainSubstring(fmt.Sprintf("node0%d", node)))
+		}
+		Expect(out.String()).To(ContainSubstring("oam-ipv4=32.68.51.139"))
+		Expect(out.String()).To(MatchRegexp(`ControlPlane\s+2\s+2`))
+		Expect(out.String()).To(MatchRegexp(`Worker\s+2\s+2`))
+	})
+
+	It("Should print a partial placement when the inventory is too small", func() {
+		sipFile := writeInventory()
+		sip, err := readSIPCluster(sipFile)
+		Expect(err).NotTo(HaveOccurred())
+		nodeSet := sip.Spec.Nodes[airshipv1.RoleWorker]
+		nodeSet.Count.Active = 3
+		sip.Spec.Nodes[airshipv1.RoleWorker] = nodeSet
+		inventory, err := newInventory(filepath.Join(dir, "inventory"))
+		Expect(err).NotTo(HaveOccurred())
+
+		machines, err := schedule(*sip, inventory)
+		Expect(err).To(HaveOccurred())
+
+		out := &bytes.Buffer{}
+		printMachines(out, *sip, machines)
+		Expect(out.String()).To(MatchRegexp(`Worker\s+2\s+3`))
+	})
+
+	It("Should render the infrastructure services of a SIPCluster from a file inventory", func() {
+		sip, err := readSIPCluster(writeInventory())
+		Expect(err).NotTo(HaveOccurred())
+		inventoryDir := filepath.Join(dir, "inventory")
+		inventory, err := newInventory(inventoryDir)
+		Expect(err).NotTo(HaveOccurred())
+		manifests, err := readManifests(inventoryDir)
+		Expect(err).NotTo(HaveOccurred())
+		source := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(manifests...).Build()
+
+		objs, err := render(sip, inventory, source)
+		Expect(err).NotTo(HaveOccurred())
+
+		out := &bytes.Buffer{}
+		Expect(printManifests(out, objs)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("kind: Deployment"))
+		Expect(out.String()).To(ContainSubstring("image: " + airshipv1.DefaultJumpHostImage))
+		Expect(out.String()).To(ContainSubstring("backend http-backend"))
+		Expect(out.String()).NotTo(ContainSubstring("resourceVersion"))
+	})
+
+	It("Should summarize the services of a SIPCluster", func() {
+		sip, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		labels := map[string]string{
+			bmh.SipClusterNameLabel: sip.Name,
+			airshipsvc.ServiceLabel: airshipsvc.JumpHostServiceName,
+		}
+		replicas := int32(1)
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "jumphost", Namespace: sip.Namespace, Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "jumphost", Namespace: sip.Namespace, Labels: labels},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeNodePort,
+				Ports: []corev1.ServicePort{{Port: 22, NodePort: 30000, Protocol: corev1.ProtocolTCP}},
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment, service).Build()
+
+		out := &bytes.Buffer{}
+		Expect(printServices(out, *sip, c)).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`jumphost\s+1/1\s+NodePort\s+22:30000/TCP`))
+	})
+})
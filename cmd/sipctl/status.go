@@ -0,0 +1,150 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+	airshipsvc "sipcluster/pkg/services"
+)
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	namespace := fs.String("n", "default", "Namespace of the SIPCluster.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: status [-n namespace] <SIPCluster name>\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one SIPCluster name must be provided")
+	}
+
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	sip := &airshipv1.SIPCluster{}
+	if err = c.Get(context.Background(), client.ObjectKey{Namespace: *namespace, Name: fs.Arg(0)}, sip); err != nil {
+		return err
+	}
+
+	machines := &bmh.MachineList{
+		Log: ctrl.Log.WithName("machines"),
+	}
+	if err = machines.GetCluster(*sip, bmh.NewKubernetesInventory(c)); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "SIPCluster: %s/%s\n\n", sip.Namespace, sip.Name)
+	printConditions(w, sip.Status.Conditions)
+	printHosts(w, machines)
+	if err = printServices(w, *sip, c); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func printConditions(w io.Writer, conditions []metav1.Condition) {
+	fmt.Fprintln(w, "CONDITION\tSTATUS\tREASON\tMESSAGE")
+	for _, condition := range conditions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+	}
+	fmt.Fprintln(w)
+}
+
+func printHosts(w io.Writer, machines *bmh.MachineList) {
+	names := make([]string, 0, len(machines.Machines))
+	for name := range machines.Machines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "BMH\tROLE\tPROVISIONING\tPOWERED ON")
+	for _, name := range names {
+		host := machines.Machines[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", name, host.BMHRole, host.BMH.Status.Provisioning.State,
+			host.BMH.Status.PoweredOn)
+	}
+	fmt.Fprintln(w)
+}
+
+// printServices prints the Deployments and Services of the infrastructure services of a SIPCluster.
+func printServices(w io.Writer, sip airshipv1.SIPCluster, c client.Client) error {
+	opts := []client.ListOption{
+		client.InNamespace(sip.Namespace),
+		client.MatchingLabels{bmh.SipClusterNameLabel: sip.Name},
+		client.HasLabels{airshipsvc.ServiceLabel},
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := c.List(context.Background(), deployments, opts...); err != nil {
+		return err
+	}
+	services := &corev1.ServiceList{}
+	if err := c.List(context.Background(), services, opts...); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "SERVICE\tREADY\tTYPE\tPORTS")
+	for _, deployment := range deployments.Items {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+
+		serviceType, ports := "<none>", "<none>"
+		for _, service := range services.Items {
+			if service.Name == deployment.Name {
+				serviceType, ports = string(service.Spec.Type), formatPorts(service.Spec.Ports)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%s\t%s\n", deployment.Name, deployment.Status.ReadyReplicas, replicas,
+			serviceType, ports)
+	}
+	return nil
+}
+
+func formatPorts(servicePorts []corev1.ServicePort) string {
+	ports := make([]string, 0, len(servicePorts))
+	for _, port := range servicePorts {
+		if port.NodePort != 0 {
+			ports = append(ports, fmt.Sprintf("%d:%d/%s", port.Port, port.NodePort, port.Protocol))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d/%s", port.Port, port.Protocol))
+		}
+	}
+	return strings.Join(ports, ",")
+}
@@ -7,6 +7,7 @@ require (
 	github.com/metal3-io/baremetal-operator v0.0.0-20201014161845-a6d4f1fc3228
 	github.com/onsi/ginkgo v1.14.2
 	github.com/onsi/gomega v1.10.3
+	github.com/prometheus/client_golang v1.7.1
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	k8s.io/api v0.19.2
 	k8s.io/apimachinery v0.19.2
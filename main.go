@@ -21,6 +21,7 @@ import (
 	"os"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -58,14 +59,29 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhooks bool
+	var nodePortRange string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks for SIPClusters. "+
+			"Enabling this requires a serving certificate for the webhook server, e.g. from cert-manager.")
+	flag.StringVar(&nodePortRange, "service-node-port-range", "30000-32767",
+		"The service node port range of the cluster, which the node ports of the infrastructure services must be in. "+
+			"It must match the --service-node-port-range of the API server.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	portRange, err := utilnet.ParsePortRange(nodePortRange)
+	if err != nil {
+		setupLog.Error(err, "invalid service node port range", "range", nodePortRange)
+		os.Exit(1)
+	}
+	airshipv1.NodePortRange = airshipv1.PortRange{Start: portRange.Base, End: portRange.Base + portRange.Size - 1}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -79,12 +95,26 @@ func main() {
 	}
 
 	if err = (&controllers.SIPClusterReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("sipcluster-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SIPCluster")
+		os.Exit(1)
+	}
+	if err = (&controllers.SIPInventoryReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "SIPCluster")
+		setupLog.Error(err, "unable to create controller", "controller", "SIPInventory")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = (&airshipv1.SIPCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SIPCluster")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
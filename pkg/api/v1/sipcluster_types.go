@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -48,8 +49,32 @@ type SIPClusterSpec struct {
 
 	// Services defines the services that are deployed when a SIPCluster is provisioned.
 	Services SIPClusterServices `json:"services"`
+
+	// ReclaimPolicy defines what happens to the BMHs and services of the SIPCluster when it is deleted.
+	// Defaults to Release.
+	// +kubebuilder:default=Release
+	// +optional
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
 }
 
+// ReclaimPolicy defines what happens to the BMHs and services of a SIPCluster when it is deleted.
+// +kubebuilder:validation:Enum=Retain;Release;Deprovision
+type ReclaimPolicy string
+
+const (
+	// ReclaimPolicyRetain keeps the BMHs scheduled to the SIPCluster, and keeps its services, e.g. for forensic work.
+	// Both have to be cleaned up by hand. The objects of the services lose their SIP labels and are annotated with
+	// sip.airshipit.org/retained-from instead, and a new SIPCluster of the same name does not take them over.
+	ReclaimPolicyRetain ReclaimPolicy = "Retain"
+	// ReclaimPolicyRelease removes the services of the SIPCluster, and returns its BMHs to the pool of free hosts
+	// as they are.
+	ReclaimPolicyRelease ReclaimPolicy = "Release"
+	// ReclaimPolicyDeprovision removes the services of the SIPCluster, and deprovisions its BMHs. A BMH is only
+	// returned to the pool of free hosts once it has been deprovisioned, unless it is being deleted, has failed, or is
+	// not deprovisioned within 30 minutes.
+	ReclaimPolicyDeprovision ReclaimPolicy = "Deprovision"
+)
+
 // SIPClusterServices defines the services that are deployed when a SIPCluster is provisioned.
 type SIPClusterServices struct {
 	// LoadBalancer defines the sub-cluster load balancer services.
@@ -77,7 +102,10 @@ func (s SIPClusterServices) GetAll() []SIPClusterService {
 // JumpHostService is an infrastructure service type that represents the sub-cluster jump-host service.
 type JumpHostService struct {
 	SIPClusterService `json:",inline"`
-	NodePort          int      `json:"nodePort"`
+	// NodePort is the node port that the jump host is exposed on. SIP allocates a free node port when it is omitted.
+	// +optional
+	NodePort int `json:"nodePort,omitempty"`
+	// BMC defines how the jump host reaches the BMCs of the nodes. Defaults to no proxy.
 	BMC               *BMCOpts `json:"bmc,omitempty"`
 	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
 	// NodeSSHPrivateKeys holds the name of a Secret in the same namespace as the SIPCluster CR,
@@ -92,15 +120,123 @@ LoadBalancerServiceControlPlane is an infrastructure service type that represent
 */
 type LoadBalancerServiceControlPlane struct {
 	SIPClusterService `json:",inline"`
-	NodePort          int `json:"nodePort"`
+	// NodePort is the node port that the load balancer exposes the API servers on when Ports is omitted. SIP
+	// allocates a free node port when it is omitted.
+	// +optional
+	NodePort int `json:"nodePort,omitempty"`
+	// Ports are the ports that the load balancer forwards to the control plane nodes. Defaults to the API server
+	// port 6443, exposed on NodePort and checked with HTTP requests to /readyz over TLS.
+	// +optional
+	Ports []LoadBalancerPort `json:"ports,omitempty"`
+	// Replicas is the number of load balancer pods, which run on different nodes when there are several of them.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas int `json:"replicas,omitempty"`
+	// VirtualIP runs keepalived next to the load balancer to announce its ClusterIP as a VRRP virtual IP on the
+	// nodes that SIP runs on, so that the load balancer stays reachable when a node is lost. The virtual IP is
+	// added to the external IPs of the Service instead of being its cluster IP.
+	// +optional
+	VirtualIP *VirtualIPOpts `json:"virtualIP,omitempty"`
+}
+
+// ForwardedPorts returns the ports that the load balancer forwards, with their defaults: the Ports of the load
+// balancer, or the API server port on NodePort when Ports is omitted. The API server port is checked over HTTPS by
+// HAProxy, and with TCP connections by the other engines.
+func (lb LoadBalancerServiceControlPlane) ForwardedPorts() []LoadBalancerPort {
+	ports := lb.Ports
+	if len(ports) == 0 {
+		ports = []LoadBalancerPort{{
+			// The name of the single port that control plane load balancers used to forward
+			Name:         "http",
+			FrontendPort: ControlPlaneLoadBalancerPort,
+			NodePort:     lb.NodePort,
+			HealthCheck:  HealthCheckHTTP,
+			TLSCheck:     true,
+		}}
+		if lb.Engine != "" && lb.Engine != EngineHAProxy {
+			ports[0].HealthCheck = HealthCheckTCP
+			ports[0].TLSCheck = false
+		}
+	}
+
+	forwarded := make([]LoadBalancerPort, len(ports))
+	for i, port := range ports {
+		defaultLoadBalancerPort(&port)
+		forwarded[i] = port
+	}
+	return forwarded
+}
+
+// LoadBalancerPort is a port that a load balancer forwards to the nodes of its role.
+type LoadBalancerPort struct {
+	// Name is the name of the port in the load balancer Service, after which its frontend and backend are named in
+	// the load balancer configuration.
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// FrontendPort is the port that the load balancer listens on, and that its Service exposes.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	FrontendPort int `json:"frontendPort"`
+	// BackendPort is the port of the nodes that the port is forwarded to. Defaults to FrontendPort.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	BackendPort int `json:"backendPort,omitempty"`
+	// NodePort is the node port that the port is exposed on. SIP allocates a free node port when it is omitted.
+	// +optional
+	NodePort int `json:"nodePort,omitempty"`
+	// HealthCheck is how the backends of the port are checked. Defaults to TCP. HTTP health checks are only
+	// supported by the HAProxy engine.
+	// +optional
+	HealthCheck HealthCheckType `json:"healthCheck,omitempty"`
+	// HealthCheckPath is the path that HTTP health checks request. Defaults to /readyz for HTTP health checks.
+	// +optional
+	HealthCheckPath string `json:"healthCheckPath,omitempty"`
+	// TLSCheck runs the health checks over TLS, without verifying the certificates of the backends. Only supported by
+	// the HAProxy engine.
+	// +optional
+	TLSCheck bool `json:"tlsCheck,omitempty"`
+}
+
+// HealthCheckType is how a load balancer checks the backends of a port.
+// +kubebuilder:validation:Enum=None;TCP;HTTP
+type HealthCheckType string
+
+// Supported health checks
+const (
+	// HealthCheckNone does not check the backends.
+	HealthCheckNone HealthCheckType = "None"
+	// HealthCheckTCP checks that the backends accept connections.
+	HealthCheckTCP HealthCheckType = "TCP"
+	// HealthCheckHTTP checks that the backends answer a request for the health check path with status 200.
+	HealthCheckHTTP HealthCheckType = "HTTP"
+)
+
+// VirtualIPOpts contains options for announcing the virtual IP of a control plane load balancer.
+type VirtualIPOpts struct {
+	// Interface is the network interface of the nodes that the virtual IP is announced on.
+	Interface string `json:"interface"`
+	// VirtualRouterID is the VRRP virtual router ID, which has to be unique in the network of the interface. It has no
+	// default, because the virtual IPs of SIPClusters that share the network would announce the same virtual router.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=255
+	VirtualRouterID int `json:"virtualRouterID"`
+	// Image is the image of keepalived. Defaults to osixia/keepalived:2.0.20.
+	// +optional
+	Image string `json:"image,omitempty"`
 }
 
 // LoadBalancerServiceWorker is an infrastructure service type that represents the sub-cluster load balancer service.
 type LoadBalancerServiceWorker struct {
 	SIPClusterService `json:",inline"`
+	// NodePortRange is the range of node ports that the load balancer is exposed on. SIP allocates a range of 10
+	// free node ports when it is omitted, and records it in the nodePorts of the status instead of the spec.
 	// TODO: Remove the inherited single NodePort field via refactoring. It is unused for this
 	// service since we have the below node port range instead.
-	NodePortRange PortRange `json:"nodePortRange"`
+	// +optional
+	NodePortRange PortRange `json:"nodePortRange,omitempty"`
 }
 
 // PortRange represents a range of ports.
@@ -114,6 +250,108 @@ type PortRange struct {
 // SIPClusterStatus defines the observed state of SIPCluster
 type SIPClusterStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase is the phase of reconciliation that the SIPCluster is in.
+	Phase SIPClusterPhase `json:"phase,omitempty"`
+
+	// LastSuccessfulPhase is the last phase that SIP completed for the observed generation. Once the SIPCluster has
+	// been Scheduled, later reconciles reuse the nodes in the status instead of scheduling BMHs again.
+	LastSuccessfulPhase SIPClusterPhase `json:"lastSuccessfulPhase,omitempty"`
+
+	// ObservedGeneration is the generation of the SIPCluster that the phases and nodes were reconciled for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Nodes lists the BMHs that are scheduled to the SIPCluster.
+	Nodes []NodeStatus `json:"nodes,omitempty"`
+
+	// Services lists the infrastructure services that are deployed for the SIPCluster.
+	Services []ServiceStatus `json:"services,omitempty"`
+
+	// NodePorts lists the node ports of the infrastructure services of the SIPCluster, including the ones that SIP
+	// allocated for services that omit them. Allocated node ports are kept for as long as they do not conflict.
+	NodePorts []NodePortAllocation `json:"nodePorts,omitempty"`
+}
+
+// NodePortAllocation records the node ports of an infrastructure service of a SIPCluster.
+type NodePortAllocation struct {
+	// Service identifies the service by the name of its objects, e.g. jumphost-<SIPCluster>, followed by the name of
+	// the port for the ports of control plane load balancers, e.g. loadbalancer-controlplane-<SIPCluster>/https.
+	Service string `json:"service"`
+	// Ports holds the node ports of the service. A service with a single node port has a range of one port.
+	Ports PortRange `json:"ports"`
+	// Allocated is whether SIP allocated the node ports, as opposed to them being set in the spec.
+	Allocated bool `json:"allocated,omitempty"`
+}
+
+// SIPClusterPhase is a phase of the reconciliation of a SIPCluster. The phases are run in the order in which they
+// are declared.
+// +kubebuilder:validation:Enum=Scheduling;Scheduled;ServicesDeploying;Labeling;Ready
+type SIPClusterPhase string
+
+// Possible phases of a SIPCluster
+const (
+	// PhaseScheduling means that SIP is selecting the BMHs of the SIPCluster.
+	PhaseScheduling SIPClusterPhase = "Scheduling"
+	// PhaseScheduled means that the BMHs of the SIPCluster are selected and recorded in the status.
+	PhaseScheduled SIPClusterPhase = "Scheduled"
+	// PhaseServicesDeploying means that SIP is deploying the infrastructure services of the SIPCluster.
+	PhaseServicesDeploying SIPClusterPhase = "ServicesDeploying"
+	// PhaseLabeling means that SIP is labeling the selected BMHs as scheduled to the SIPCluster or, once that is the
+	// last successful phase, waiting for the infrastructure services to serve.
+	PhaseLabeling SIPClusterPhase = "Labeling"
+	// PhaseReady means that the BMHs of the SIPCluster are labeled and its infrastructure services are serving.
+	PhaseReady SIPClusterPhase = "Ready"
+)
+
+// NodeStatus describes a BMH that is scheduled to a SIPCluster.
+type NodeStatus struct {
+	// Name is the name of the BMH.
+	Name string `json:"name"`
+	// Namespace is the namespace of the BMH.
+	Namespace string `json:"namespace"`
+	// Role is the role that the BMH is scheduled for.
+	Role BMHRole `json:"role"`
+	// State is whether the BMH is one of the active or the standby nodes of its role. SIP schedules active and
+	// standby nodes alike, so the first nodes of each role by name are reported as active.
+	State NodeState `json:"state"`
+	// TopologyDomain is the value of the topologyKey label of the node set on the BMH, if the node set has one.
+	TopologyDomain string `json:"topologyDomain,omitempty"`
+	// IPs holds the IP address of the BMH on each node interface that is used by the infrastructure services.
+	IPs map[string]string `json:"ips,omitempty"`
+}
+
+// NodeState is whether a BMH is an active or a standby node.
+type NodeState string
+
+// Possible states of a node
+const (
+	NodeStateActive  NodeState = "Active"
+	NodeStateStandby NodeState = "Standby"
+)
+
+// ServiceStatus describes an infrastructure service that is deployed for a SIPCluster.
+type ServiceStatus struct {
+	// Name is the name of the service instance.
+	Name string `json:"name"`
+	// Objects lists the objects of the service.
+	Objects []ServiceObject `json:"objects,omitempty"`
+	// NodePorts lists the node ports that the service is exposed on.
+	NodePorts []int32 `json:"nodePorts,omitempty"`
+	// Addresses lists the load balancer and external addresses that the service is reachable on.
+	Addresses []string `json:"addresses,omitempty"`
+	// Ready is whether the Deployments of the service are rolled out and its Services have ready endpoints.
+	Ready bool `json:"ready"`
+	// Conditions holds the Ready condition of the service, whose reason and message tell what the service is
+	// waiting for.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ServiceObject identifies an object of an infrastructure service.
+type ServiceObject struct {
+	// Kind is the kind of the object.
+	Kind string `json:"kind"`
+	// Name is the name of the object.
+	Name string `json:"name"`
 }
 
 const (
@@ -135,10 +373,38 @@ const (
 	// decommission the existing SIPCluster.
 	ReasonTypeUnableToDecommission string = "UnableToDecommission"
 
+	// ReasonTypeDeprovisioning indicates that a resource has a specified condition because SIP is waiting for the BMHs
+	// of the deleted SIPCluster to be deprovisioned.
+	ReasonTypeDeprovisioning string = "Deprovisioning"
+
 	// ReasonTypeUnschedulable indicates that a resource has a specified condition because SIP was unable to
 	// schedule BMHs for the SIPCluster.
 	ReasonTypeUnschedulable string = "Unschedulable"
 
+	// ReasonTypeServicesUnavailable indicates that a resource has a specified condition because some of the
+	// infrastructure services of the SIPCluster are not serving yet.
+	ReasonTypeServicesUnavailable string = "ServicesUnavailable"
+
+	// ReasonTypeDeploymentUnavailable indicates that a service has a specified condition because one of its
+	// Deployments has not finished rolling out.
+	ReasonTypeDeploymentUnavailable string = "DeploymentUnavailable"
+
+	// ReasonTypeEndpointsUnavailable indicates that a service has a specified condition because one of its Services
+	// has no ready endpoints.
+	ReasonTypeEndpointsUnavailable string = "EndpointsUnavailable"
+
+	// ReasonTypeLoadBalancerPending indicates that a service has a specified condition because one of its
+	// LoadBalancer Services has not been assigned an address yet.
+	ReasonTypeLoadBalancerPending string = "LoadBalancerPending"
+
+	// ReasonTypeServiceAvailable indicates that a service has a specified condition because its Deployments are
+	// rolled out and its Services have ready endpoints.
+	ReasonTypeServiceAvailable string = "ServiceAvailable"
+
+	// ReasonTypeNodePortConflict indicates that a resource has a specified condition because a node port of the
+	// SIPCluster is in use by another SIPCluster or Service, or no free node ports are left to allocate.
+	ReasonTypeNodePortConflict string = "NodePortConflict"
+
 	// ReasonTypeReconciliationSucceeded indicates that a resource has a specified condition because SIP completed
 	// reconciliation of the SIPCluster.
 	ReasonTypeReconciliationSucceeded string = "ReconciliationSucceeded"
@@ -159,16 +425,91 @@ type NodeSet struct {
 	// TopologyKey is similar to the same named field in the kubernetes Pod anti-affinity API.
 	// If two BMHs are labeled with this key and have identical values for that
 	// label, they are considered to be in the same topology domain, and thus only one will be scheduled.
+	// Defaults to vino.airshipit.org/host.
 	TopologyKey string `json:"topologyKey,omitempty"`
 	// Count defines the scale expectations for the Nodes
 	Count *NodeCount `json:"count,omitempty"`
 }
 
 type SIPClusterService struct {
-	Image         string            `json:"image"`
-	NodeLabels    map[string]string `json:"nodeLabels,omitempty"`
-	NodeInterface string            `json:"nodeInterfaceId,omitempty"`
-	ClusterIP     *string           `json:"clusterIP,omitempty"`
+	// Image is the image of the service. Defaults to quay.io/airshipit/jump-host:latest for jump hosts. Load balancers
+	// that leave it unset run the image of their engine, haproxy:2.3.2 for HAProxy and nginx:1.19.6 for NGINX.
+	Image string `json:"image,omitempty"`
+	// NodeLabels is the node selector of the pods of the service in the cluster that SIP runs in. Defaults to the
+	// control plane nodes for load balancers, unless Affinity is set.
+	// +optional
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+	// Affinity holds the scheduling constraints of the pods of the service.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// Tolerations are the tolerations of the pods of the service. Defaults to tolerating the control plane nodes
+	// for load balancers whose NodeLabels and Affinity are not set.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// NodeInterface is the network interface of the BMHs that the service reaches them on. Defaults to oam-ipv4.
+	NodeInterface string `json:"nodeInterfaceId,omitempty"`
+	// Engine is the proxy that a load balancer runs. Defaults to HAProxy for load balancers, and is not used by jump
+	// hosts.
+	// +optional
+	Engine LoadBalancerEngine `json:"engine,omitempty"`
+	// Metrics exposes the statistics of a load balancer to Prometheus. It is only supported by the HAProxy engine,
+	// and is not used by jump hosts.
+	// +optional
+	Metrics *MetricsOpts `json:"metrics,omitempty"`
+	// ServiceType is the type of the Service that exposes the service. NodePort services are reached on the
+	// node ports of the SIPCluster, LoadBalancer services on the address of an external load balancer such as
+	// MetalLB, and ClusterIP services on their ExternalIPs. Defaults to NodePort.
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer;ClusterIP
+	// +optional
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	// ClusterIP is the cluster IP requested for the Service. It is allocated by the API server when omitted. It is
+	// the virtual IP of a control plane load balancer that sets VirtualIP.
+	// +optional
+	ClusterIP *string `json:"clusterIP,omitempty"`
+	// LoadBalancerIP is the address requested from the load balancer of a LoadBalancer service.
+	// +optional
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+	// ExternalIPs are addresses routed to the nodes that the Service also accepts traffic on.
+	// +optional
+	ExternalIPs []string `json:"externalIPs,omitempty"`
+}
+
+// LoadBalancerEngine is the proxy that a load balancer runs.
+// +kubebuilder:validation:Enum=HAProxy;NGINX
+type LoadBalancerEngine string
+
+// Supported load balancer engines
+const (
+	// EngineHAProxy runs HAProxy, which checks the health of the backends of control plane load balancers.
+	EngineHAProxy LoadBalancerEngine = "HAProxy"
+	// EngineNGINX runs the stream proxy of NGINX.
+	EngineNGINX LoadBalancerEngine = "NGINX"
+)
+
+// MetricsOpts contains options for exposing the statistics of a load balancer to Prometheus.
+type MetricsOpts struct {
+	// Port is the port that the load balancer pods serve their Prometheus metrics on, at /metrics, and their
+	// statistics page on, at /stats. It is exposed by a ClusterIP Service of its own. Defaults to 8405.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int `json:"port,omitempty"`
+	// ServiceMonitor creates a Prometheus Operator ServiceMonitor for the metrics Service. It requires the
+	// ServiceMonitor CRD to be installed in the cluster that SIP runs in.
+	// +optional
+	ServiceMonitor *ServiceMonitorOpts `json:"serviceMonitor,omitempty"`
+}
+
+// ServiceMonitorOpts contains options for the ServiceMonitor of a load balancer.
+type ServiceMonitorOpts struct {
+	// Labels are the labels of the ServiceMonitor, which Prometheus instances select their ServiceMonitors by.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Interval is the interval that the metrics are scraped at, e.g. 30s. Prometheus scrapes at its global interval
+	// when it is omitted.
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
 }
 
 // BMCOpts contains options for BMC communication.
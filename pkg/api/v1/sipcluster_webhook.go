@@ -0,0 +1,537 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// DefaultMinNodePort and DefaultMaxNodePort bound the default service node port range of Kubernetes clusters.
+	DefaultMinNodePort = 30000
+	DefaultMaxNodePort = 32767
+
+	// MaxNodePortRangeSize is the largest number of ports in the node port range of a worker load balancer. The
+	// load balancer Service and configuration have an entry for each port in the range.
+	MaxNodePortRangeSize = 1000
+
+	// ControlPlaneLoadBalancerPort is the port that control plane load balancers forward to the API servers.
+	ControlPlaneLoadBalancerPort = 6443
+)
+
+// NodePortRange is the service node port range of the Kubernetes cluster that the infrastructure services are deployed
+// in. Their node ports have to be in this range. The manager sets it from its --service-node-port-range flag.
+var NodePortRange = PortRange{Start: DefaultMinNodePort, End: DefaultMaxNodePort}
+
+// Defaults of the SIPCluster spec
+const (
+	// DefaultLoadBalancerImage is the image of the HAProxy load balancer services.
+	DefaultLoadBalancerImage = "haproxy:2.3.2"
+	// DefaultNGINXLoadBalancerImage is the image of the NGINX load balancer services.
+	DefaultNGINXLoadBalancerImage = "nginx:1.19.6"
+	// DefaultJumpHostImage is the image of the jump host services.
+	DefaultJumpHostImage = "quay.io/airshipit/jump-host:latest"
+	// DefaultNodeInterface is the network interface of the BMHs that the services reach them on.
+	DefaultNodeInterface = "oam-ipv4"
+	// DefaultTopologyKey is the topology key of node sets, so that no two nodes of a set are VMs of the same host.
+	DefaultTopologyKey = "vino.airshipit.org/host"
+	// DefaultKeepalivedImage is the image of keepalived, which announces the virtual IP of load balancers.
+	DefaultKeepalivedImage = "osixia/keepalived:2.0.20"
+	// ControlPlaneNodeRoleLabel is the label, and the taint, of the control plane nodes of the cluster that SIP
+	// runs in. Load balancers are placed on these nodes by default.
+	ControlPlaneNodeRoleLabel = "node-role.kubernetes.io/master"
+	// DefaultHealthCheckPath is the path that HTTP health checks of control plane load balancers request.
+	DefaultHealthCheckPath = "/readyz"
+	// DefaultMetricsPort is the port that load balancers serve their metrics on.
+	DefaultMetricsPort = 8405
+	// DefaultWorkerNodePortRangeSize is the number of node ports that are allocated to a worker load balancer that
+	// omits its node port range.
+	DefaultWorkerNodePortRangeSize = 10
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of SIPClusters with the manager.
+func (r *SIPCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-airship-airshipit-org-v1-sipcluster,mutating=true,failurePolicy=fail,groups=airship.airshipit.org,resources=sipclusters,versions=v1,name=msipcluster.kb.io
+
+var _ webhook.Defaulter = &SIPCluster{}
+
+// Default implements webhook.Defaulter. It fills in the fields of the spec that SIP would otherwise pick for the
+// SIPCluster, so that the stored spec is the one that SIP reconciles. Node ports and worker node port ranges are not
+// defaulted: the free ones are only known when SIP reconciles the SIPCluster, which records them in its status.
+func (r *SIPCluster) Default() {
+	for role, nodeSet := range r.Spec.Nodes {
+		if nodeSet.TopologyKey == "" {
+			nodeSet.TopologyKey = DefaultTopologyKey
+			r.Spec.Nodes[role] = nodeSet
+		}
+	}
+
+	services := &r.Spec.Services
+	for i := range services.LoadBalancerControlPlane {
+		placeLoadBalancer(&services.LoadBalancerControlPlane[i].SIPClusterService)
+	}
+	for i := range services.LoadBalancerWorker {
+		placeLoadBalancer(&services.LoadBalancerWorker[i].SIPClusterService)
+	}
+	r.DefaultServices()
+}
+
+// DefaultServices fills in the fields of the services that SIP is unable to render them without. Unlike Default, it
+// leaves the placement of load balancers and the topology keys of node sets alone, because their defaults would move
+// the pods and hosts of SIPClusters that were stored without them. The reconciler applies it to SIPClusters that were
+// not defaulted at admission.
+func (r *SIPCluster) DefaultServices() {
+	services := &r.Spec.Services
+	for i := range services.LoadBalancerControlPlane {
+		lb := &services.LoadBalancerControlPlane[i]
+		defaultLoadBalancer(&lb.SIPClusterService)
+		if lb.Replicas == 0 {
+			lb.Replicas = 1
+		}
+		for j := range lb.Ports {
+			defaultLoadBalancerPort(&lb.Ports[j])
+		}
+		if lb.VirtualIP != nil && lb.VirtualIP.Image == "" {
+			lb.VirtualIP.Image = DefaultKeepalivedImage
+		}
+	}
+	for i := range services.LoadBalancerWorker {
+		defaultLoadBalancer(&services.LoadBalancerWorker[i].SIPClusterService)
+	}
+	for i := range services.JumpHost {
+		jumpHost := &services.JumpHost[i]
+		if jumpHost.Image == "" {
+			jumpHost.Image = DefaultJumpHostImage
+		}
+		defaultService(&jumpHost.SIPClusterService)
+		if jumpHost.BMC == nil {
+			jumpHost.BMC = &BMCOpts{}
+		}
+	}
+}
+
+// defaultLoadBalancer runs HAProxy in a load balancer unless its engine is set. The image is left unset, so that the
+// load balancer runs the image of the engine that it is rendered with, also after its engine changes. Metrics are
+// served on the default metrics port unless another one is set.
+func defaultLoadBalancer(service *SIPClusterService) {
+	if service.Engine == "" {
+		service.Engine = EngineHAProxy
+	}
+	defaultService(service)
+	if service.Metrics != nil && service.Metrics.Port == 0 {
+		service.Metrics.Port = DefaultMetricsPort
+	}
+}
+
+// placeLoadBalancer places the pods of a load balancer on the control plane nodes unless their placement is set. The
+// pods only tolerate the control plane taint when they are placed on the control plane nodes by default.
+func placeLoadBalancer(service *SIPClusterService) {
+	if service.NodeLabels != nil || service.Affinity != nil {
+		return
+	}
+	service.NodeLabels = map[string]string{ControlPlaneNodeRoleLabel: ""}
+	if service.Tolerations == nil {
+		service.Tolerations = []corev1.Toleration{
+			{Key: ControlPlaneNodeRoleLabel, Effect: corev1.TaintEffectNoSchedule},
+		}
+	}
+}
+
+// defaultLoadBalancerPort forwards a port of a load balancer to the same port of the nodes, and checks its backends
+// with TCP connections, unless its backend port and health check are set.
+func defaultLoadBalancerPort(port *LoadBalancerPort) {
+	if port.BackendPort == 0 {
+		port.BackendPort = port.FrontendPort
+	}
+	if port.HealthCheck == "" {
+		port.HealthCheck = HealthCheckTCP
+	}
+	if port.HealthCheck == HealthCheckHTTP && port.HealthCheckPath == "" {
+		port.HealthCheckPath = DefaultHealthCheckPath
+	}
+}
+
+func defaultService(service *SIPClusterService) {
+	if service.NodeInterface == "" {
+		service.NodeInterface = DefaultNodeInterface
+	}
+	if service.ServiceType == "" {
+		service.ServiceType = corev1.ServiceTypeNodePort
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-airship-airshipit-org-v1-sipcluster,mutating=false,failurePolicy=fail,groups=airship.airshipit.org,resources=sipclusters,versions=v1,name=vsipcluster.kb.io
+
+var _ webhook.Validator = &SIPCluster{}
+
+// ValidateCreate implements webhook.Validator.
+func (r *SIPCluster) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator.
+func (r *SIPCluster) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator. A SIPCluster can always be deleted.
+func (r *SIPCluster) ValidateDelete() error {
+	return nil
+}
+
+// validate rejects the specs that SIP is unable to reconcile.
+func (r *SIPCluster) validate() error {
+	specPath := field.NewPath("spec")
+	allErrs := validateNodes(r.Spec.Nodes, specPath.Child("nodes"))
+	allErrs = append(allErrs, validateServices(r.Spec.Services, specPath.Child("services"))...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("SIPCluster").GroupKind(), r.Name, allErrs)
+}
+
+func validateNodes(nodes map[BMHRole]NodeSet, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for role, nodeSet := range nodes {
+		rolePath := path.Key(string(role))
+		if role != RoleControlPlane && role != RoleWorker {
+			allErrs = append(allErrs, field.NotSupported(rolePath, role,
+				[]string{string(RoleControlPlane), string(RoleWorker)}))
+		}
+
+		countPath := rolePath.Child("count")
+		switch {
+		case nodeSet.Count == nil:
+			allErrs = append(allErrs, field.Required(countPath, "the number of nodes to schedule is required"))
+		case nodeSet.Count.Active < 0:
+			allErrs = append(allErrs, field.Invalid(countPath.Child("active"), nodeSet.Count.Active,
+				"must not be negative"))
+		case nodeSet.Count.Standby < 0:
+			allErrs = append(allErrs, field.Invalid(countPath.Child("standby"), nodeSet.Count.Standby,
+				"must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+func validateServices(services SIPClusterServices, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, lb := range services.LoadBalancerControlPlane {
+		lbPath := path.Child("loadBalancerControlPlane").Index(i)
+		allErrs = append(allErrs, validateNodePort(lb.NodePort, lbPath.Child("nodePort"))...)
+		allErrs = append(allErrs, validateReplicas(lb, lbPath)...)
+		allErrs = append(allErrs, validateControlPlanePorts(lb, lbPath)...)
+		allErrs = append(allErrs, metav1validation.ValidateLabels(lb.NodeLabels, lbPath.Child("nodeLabels"))...)
+		frontendPorts := []int{}
+		for _, port := range lb.ForwardedPorts() {
+			frontendPorts = append(frontendPorts, port.FrontendPort)
+		}
+		allErrs = append(allErrs, validateMetrics(lb.SIPClusterService, frontendPorts, lbPath)...)
+		allErrs = append(allErrs, validateEngineImage(lb.SIPClusterService, lbPath)...)
+	}
+	for i, lb := range services.LoadBalancerWorker {
+		lbPath := path.Child("loadBalancerWorker").Index(i)
+		allErrs = append(allErrs, validatePortRange(lb.NodePortRange, lbPath.Child("nodePortRange"))...)
+		allErrs = append(allErrs, validateExposure(lb.SIPClusterService, lb.NodePortRange != (PortRange{}),
+			lbPath.Child("nodePortRange"), lbPath)...)
+		allErrs = append(allErrs, metav1validation.ValidateLabels(lb.NodeLabels, lbPath.Child("nodeLabels"))...)
+		allErrs = append(allErrs, validateMetrics(lb.SIPClusterService, nil, lbPath)...)
+		allErrs = append(allErrs, validateEngineImage(lb.SIPClusterService, lbPath)...)
+	}
+	for i, jumpHost := range services.JumpHost {
+		allErrs = append(allErrs, validateJumpHost(jumpHost, path.Child("jumpHost").Index(i))...)
+	}
+	return allErrs
+}
+
+// validateReplicas checks the replicas of a control plane load balancer and the virtual IP that they announce.
+func validateReplicas(lb LoadBalancerServiceControlPlane, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if lb.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("replicas"), lb.Replicas, "must not be negative"))
+	}
+	if lb.VirtualIP == nil {
+		return allErrs
+	}
+
+	vipPath := path.Child("virtualIP")
+	if lb.ClusterIP == nil || *lb.ClusterIP == "" {
+		allErrs = append(allErrs, field.Required(path.Child("clusterIP"),
+			"the virtual IP is required when virtualIP is set"))
+	}
+	if lb.VirtualIP.Interface == "" {
+		allErrs = append(allErrs, field.Required(vipPath.Child("interface"),
+			"the interface that the virtual IP is announced on is required"))
+	}
+	switch {
+	case lb.VirtualIP.VirtualRouterID == 0:
+		allErrs = append(allErrs, field.Required(vipPath.Child("virtualRouterID"),
+			"a virtual router ID that is unique in the network of the interface is required"))
+	case lb.VirtualIP.VirtualRouterID < 0 || lb.VirtualIP.VirtualRouterID > 255:
+		allErrs = append(allErrs, field.Invalid(vipPath.Child("virtualRouterID"), lb.VirtualIP.VirtualRouterID,
+			"must be between 1 and 255"))
+	}
+	return allErrs
+}
+
+// validateControlPlanePorts checks the ports that a control plane load balancer forwards, which need unique names,
+// frontend ports and node ports. The node port of the load balancer is only used when it forwards no ports.
+func validateControlPlanePorts(lb LoadBalancerServiceControlPlane, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(lb.Ports) > 0 && lb.NodePort != 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child("nodePort"),
+			"must not be set with ports, set the nodePort of the ports instead"))
+	}
+
+	names := map[string]bool{}
+	frontendPorts := map[int]bool{}
+	nodePorts := map[int]bool{}
+	for i, port := range lb.Ports {
+		portPath := path.Child("ports").Index(i)
+		for _, msg := range validation.IsValidPortName(port.Name) {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name, msg))
+		}
+		if names[port.Name] {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("name"), port.Name))
+		}
+		names[port.Name] = true
+
+		allErrs = append(allErrs, validatePort(port.FrontendPort, portPath.Child("frontendPort"))...)
+		if frontendPorts[port.FrontendPort] {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("frontendPort"), port.FrontendPort))
+		}
+		frontendPorts[port.FrontendPort] = true
+		if port.BackendPort != 0 {
+			allErrs = append(allErrs, validatePort(port.BackendPort, portPath.Child("backendPort"))...)
+		}
+
+		if port.NodePort != 0 {
+			if lb.ServiceType == corev1.ServiceTypeClusterIP {
+				allErrs = append(allErrs, field.Forbidden(portPath.Child("nodePort"),
+					"must not be set for ClusterIP services"))
+			}
+			allErrs = append(allErrs, validateNodePort(port.NodePort, portPath.Child("nodePort"))...)
+			if nodePorts[port.NodePort] {
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("nodePort"), port.NodePort))
+			}
+			nodePorts[port.NodePort] = true
+		}
+		allErrs = append(allErrs, validateHealthCheck(port, lb.Engine, portPath)...)
+	}
+	return append(allErrs, validateExposure(lb.SIPClusterService, lb.NodePort != 0, path.Child("nodePort"), path)...)
+}
+
+// validateHealthCheck checks the health check of a port, which only requests a path when it is an HTTP health check.
+// HTTP and TLS health checks are only run by HAProxy; NGINX only takes a backend out after failed connections.
+func validateHealthCheck(port LoadBalancerPort, engine LoadBalancerEngine, path *field.Path) field.ErrorList {
+	if engine != "" && engine != EngineHAProxy {
+		allErrs := field.ErrorList{}
+		if port.HealthCheck == HealthCheckHTTP {
+			allErrs = append(allErrs, field.Forbidden(path.Child("healthCheck"),
+				"HTTP health checks are only supported by the HAProxy engine"))
+		}
+		if port.TLSCheck {
+			allErrs = append(allErrs, field.Forbidden(path.Child("tlsCheck"),
+				"TLS health checks are only supported by the HAProxy engine"))
+		}
+		if len(allErrs) > 0 {
+			return allErrs
+		}
+	}
+
+	switch {
+	case port.HealthCheckPath == "":
+		return nil
+	case port.HealthCheck != HealthCheckHTTP:
+		return field.ErrorList{field.Forbidden(path.Child("healthCheckPath"), "may only be set for HTTP health checks")}
+	case !strings.HasPrefix(port.HealthCheckPath, "/") || strings.ContainsAny(port.HealthCheckPath, " \t\r\n"):
+		return field.ErrorList{field.Invalid(path.Child("healthCheckPath"), port.HealthCheckPath,
+			"must be an absolute path without whitespace")}
+	}
+	return nil
+}
+
+func validatePort(port int, path *field.Path) field.ErrorList {
+	if port < 1 || port > 65535 {
+		return field.ErrorList{field.Invalid(path, port, "must be between 1 and 65535")}
+	}
+	return nil
+}
+
+// validateExposure checks how a service is exposed. ClusterIP services are not exposed on node ports, and only
+// LoadBalancer services request an address from a load balancer.
+func validateExposure(service SIPClusterService, hasNodePorts bool, nodePortPath, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if service.ServiceType == corev1.ServiceTypeClusterIP && hasNodePorts {
+		allErrs = append(allErrs, field.Forbidden(nodePortPath, "must not be set for ClusterIP services"))
+	}
+	if service.LoadBalancerIP != "" {
+		if service.ServiceType != corev1.ServiceTypeLoadBalancer {
+			allErrs = append(allErrs, field.Forbidden(path.Child("loadBalancerIP"),
+				"may only be set for LoadBalancer services"))
+		}
+		allErrs = append(allErrs, validateIP(service.LoadBalancerIP, path.Child("loadBalancerIP"))...)
+	}
+	if service.ClusterIP != nil && *service.ClusterIP != "" {
+		allErrs = append(allErrs, validateIP(*service.ClusterIP, path.Child("clusterIP"))...)
+	}
+	for i, ip := range service.ExternalIPs {
+		allErrs = append(allErrs, validateIP(ip, path.Child("externalIPs").Index(i))...)
+	}
+	return allErrs
+}
+
+// validateEngineImage rejects a load balancer image that is the default image of another engine, such as the HAProxy
+// image that was stored for a load balancer whose engine has been changed to NGINX since.
+func validateEngineImage(service SIPClusterService, path *field.Path) field.ErrorList {
+	engine := service.Engine
+	if engine == "" {
+		engine = EngineHAProxy
+	}
+	engineImages := map[LoadBalancerEngine]string{
+		EngineHAProxy: DefaultLoadBalancerImage,
+		EngineNGINX:   DefaultNGINXLoadBalancerImage,
+	}
+	for other, image := range engineImages {
+		if other != engine && service.Image == image {
+			return field.ErrorList{field.Invalid(path.Child("image"), service.Image,
+				fmt.Sprintf("is the image of the %s engine, remove it to run the image of the %s engine", other, engine))}
+		}
+	}
+	return nil
+}
+
+// validateMetrics checks the metrics of a load balancer, which are only exported by HAProxy. The metrics port must not
+// be one of the frontend ports of the load balancer, or a port in the node port range that worker load balancers
+// forward.
+func validateMetrics(service SIPClusterService, frontendPorts []int, path *field.Path) field.ErrorList {
+	if service.Metrics == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+	metricsPath := path.Child("metrics")
+	if service.Engine != "" && service.Engine != EngineHAProxy {
+		allErrs = append(allErrs, field.Forbidden(metricsPath, "is only supported by the HAProxy engine"))
+	}
+	port := service.Metrics.Port
+	switch {
+	case port < 0 || port > 65535:
+		allErrs = append(allErrs, field.Invalid(metricsPath.Child("port"), port, "must be between 1 and 65535"))
+	case port >= NodePortRange.Start && port <= NodePortRange.End:
+		allErrs = append(allErrs, field.Invalid(metricsPath.Child("port"), port,
+			"must not be a port that load balancers forward"))
+	}
+	for _, frontendPort := range frontendPorts {
+		if port == frontendPort {
+			allErrs = append(allErrs, field.Invalid(metricsPath.Child("port"), port,
+				"must not be a port that the load balancer forwards"))
+		}
+	}
+	if monitor := service.Metrics.ServiceMonitor; monitor != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabels(monitor.Labels,
+			metricsPath.Child("serviceMonitor", "labels"))...)
+	}
+	return allErrs
+}
+
+func validateIP(ip string, path *field.Path) field.ErrorList {
+	if net.ParseIP(ip) == nil {
+		return field.ErrorList{field.Invalid(path, ip, "must be a valid IP address")}
+	}
+	return nil
+}
+
+func validateJumpHost(jumpHost JumpHostService, path *field.Path) field.ErrorList {
+	allErrs := validateNodePort(jumpHost.NodePort, path.Child("nodePort"))
+	allErrs = append(allErrs, validateExposure(jumpHost.SIPClusterService, jumpHost.NodePort != 0,
+		path.Child("nodePort"), path)...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(jumpHost.NodeLabels, path.Child("nodeLabels"))...)
+	if jumpHost.Engine != "" {
+		allErrs = append(allErrs, field.Forbidden(path.Child("engine"), "may only be set for load balancers"))
+	}
+	if jumpHost.Metrics != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("metrics"), "may only be set for load balancers"))
+	}
+	if jumpHost.NodeSSHPrivateKeys == "" {
+		allErrs = append(allErrs, field.Required(path.Child("nodeSSHPrivateKeys"),
+			"the name of the Secret holding the node SSH private keys is required"))
+	}
+	for i, key := range jumpHost.SSHAuthorizedKeys {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("sshAuthorizedKeys").Index(i), key, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateNodePort checks that a node port is in the node port range. A port of 0 is allocated by SIP.
+func validateNodePort(port int, path *field.Path) field.ErrorList {
+	if port == 0 {
+		return nil
+	}
+	return validatePortInRange(port, path)
+}
+
+// validatePortRange checks that a port range is in the node port range. An empty range is allocated by SIP.
+func validatePortRange(portRange PortRange, path *field.Path) field.ErrorList {
+	if portRange == (PortRange{}) {
+		return nil
+	}
+
+	allErrs := validatePortInRange(portRange.Start, path.Child("start"))
+	allErrs = append(allErrs, validatePortInRange(portRange.End, path.Child("end"))...)
+	if len(allErrs) > 0 {
+		return allErrs
+	}
+
+	switch {
+	case portRange.End < portRange.Start:
+		allErrs = append(allErrs, field.Invalid(path.Child("end"), portRange.End,
+			"must not be lower than the start of the range"))
+	case portRange.End-portRange.Start+1 > MaxNodePortRangeSize:
+		allErrs = append(allErrs, field.Invalid(path, fmt.Sprintf("%d-%d", portRange.Start, portRange.End),
+			fmt.Sprintf("must not hold more than %d ports", MaxNodePortRangeSize)))
+	}
+	return allErrs
+}
+
+func validatePortInRange(port int, path *field.Path) field.ErrorList {
+	if port < NodePortRange.Start || port > NodePortRange.End {
+		return field.ErrorList{field.Invalid(path, port,
+			fmt.Sprintf("must be in the node port range %d-%d", NodePortRange.Start, NodePortRange.End))}
+	}
+	return nil
+}
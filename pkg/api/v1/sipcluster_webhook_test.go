@@ -0,0 +1,426 @@
+package v1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("SIPCluster webhook", func() {
+	var sip *airshipv1.SIPCluster
+
+	BeforeEach(func() {
+		sip, _ = testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 30001
+	})
+
+	// causes returns the fields of the causes of an Invalid error.
+	causes := func(err error) []string {
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		fields := []string{}
+		for _, cause := range err.(apierrors.APIStatus).Status().Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	It("Fills in the defaults of the services and node sets", func() {
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{}
+		sip.Spec.Services.JumpHost[0].NodeInterface = ""
+		nodeSet := sip.Spec.Nodes[airshipv1.RoleWorker]
+		nodeSet.TopologyKey = ""
+		sip.Spec.Nodes[airshipv1.RoleWorker] = nodeSet
+
+		sip.Default()
+
+		services := sip.Spec.Services
+		Expect(services.LoadBalancerControlPlane[0].Image).To(BeEmpty())
+		Expect(services.LoadBalancerControlPlane[0].Engine).To(Equal(airshipv1.EngineHAProxy))
+		Expect(services.LoadBalancerControlPlane[0].Replicas).To(Equal(1))
+		Expect(services.LoadBalancerControlPlane[0].VirtualIP).To(BeNil())
+		Expect(services.LoadBalancerWorker[0].Image).To(BeEmpty())
+		Expect(services.LoadBalancerWorker[0].NodePortRange).To(Equal(airshipv1.PortRange{}))
+		Expect(services.JumpHost[0].Image).To(Equal("quay.io/airshipit/jump-host"))
+		Expect(services.JumpHost[0].NodeInterface).To(Equal(airshipv1.DefaultNodeInterface))
+		Expect(services.JumpHost[0].BMC).To(Equal(&airshipv1.BMCOpts{}))
+		Expect(services.JumpHost[0].ServiceType).To(Equal(corev1.ServiceTypeNodePort))
+		Expect(services.JumpHost[0].Engine).To(BeEmpty())
+		Expect(services.JumpHost[0].NodeLabels).To(BeNil())
+		Expect(services.JumpHost[0].Tolerations).To(BeNil())
+		for _, lb := range []airshipv1.SIPClusterService{
+			services.LoadBalancerControlPlane[0].SIPClusterService,
+			services.LoadBalancerWorker[0].SIPClusterService,
+		} {
+			Expect(lb.NodeLabels).To(Equal(map[string]string{airshipv1.ControlPlaneNodeRoleLabel: ""}))
+			Expect(lb.Tolerations).To(Equal([]corev1.Toleration{
+				{Key: airshipv1.ControlPlaneNodeRoleLabel, Effect: corev1.TaintEffectNoSchedule},
+			}))
+		}
+		Expect(sip.Spec.Nodes[airshipv1.RoleWorker].TopologyKey).To(Equal(airshipv1.DefaultTopologyKey))
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Keeps the fields that are set", func() {
+		sip.Spec.Services.LoadBalancerWorker[0].NodeInterface = "pxe-ipv4"
+		sip.Spec.Services.JumpHost[0].BMC = &airshipv1.BMCOpts{Proxy: true}
+		sip.Spec.Services.LoadBalancerControlPlane[0].ServiceType = corev1.ServiceTypeLoadBalancer
+		sip.Spec.Services.LoadBalancerControlPlane[0].Affinity = &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: "node-role.kubernetes.io/infra", Operator: corev1.NodeSelectorOpExists},
+						},
+					}},
+				},
+			},
+		}
+		sip.Spec.Services.LoadBalancerWorker[0].NodeLabels = map[string]string{"node-role.kubernetes.io/infra": ""}
+		sip.Spec.Services.LoadBalancerWorker[0].Tolerations = []corev1.Toleration{}
+		sip.Spec.Services.LoadBalancerControlPlane[0].Replicas = 3
+		sip.Spec.Services.LoadBalancerWorker[0].Engine = airshipv1.EngineNGINX
+		expected := sip.DeepCopy()
+		// The load balancers are not placed on the control plane nodes, so they do not tolerate their taint
+		expected.Spec.Services.LoadBalancerControlPlane[0].Engine = airshipv1.EngineHAProxy
+		expected.Spec.Services.LoadBalancerWorker[0].ServiceType = corev1.ServiceTypeNodePort
+		expected.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeNodePort
+
+		sip.Default()
+		Expect(sip).To(Equal(expected))
+	})
+
+	It("Only tolerates the control plane taint when the load balancer is placed on the control plane nodes", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodeLabels = map[string]string{
+			airshipv1.ControlPlaneNodeRoleLabel: "",
+		}
+		sip.Spec.Services.LoadBalancerWorker[0].NodeLabels = map[string]string{"node-role.kubernetes.io/infra": ""}
+
+		sip.Default()
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].Tolerations).To(BeNil())
+		Expect(sip.Spec.Services.LoadBalancerWorker[0].Tolerations).To(BeNil())
+	})
+
+	It("Rejects the image of another engine", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Image = airshipv1.DefaultLoadBalancerImage
+		sip.Spec.Services.LoadBalancerWorker[0].Engine = airshipv1.EngineNGINX
+		sip.Spec.Services.LoadBalancerWorker[0].Image = airshipv1.DefaultLoadBalancerImage
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerWorker[0].image"))
+	})
+
+	It("Admits a valid SIPCluster", func() {
+		Expect(sip.ValidateCreate()).To(Succeed())
+		Expect(sip.ValidateUpdate(sip.DeepCopy())).To(Succeed())
+	})
+
+	It("Rejects a node set without a count", func() {
+		nodeSet := sip.Spec.Nodes[airshipv1.RoleWorker]
+		nodeSet.Count = nil
+		sip.Spec.Nodes[airshipv1.RoleWorker] = nodeSet
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.nodes[Worker].count"))
+	})
+
+	It("Rejects an unknown role", func() {
+		sip.Spec.Nodes["Storage"] = airshipv1.NodeSet{
+			LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "storage"}},
+			Count:         &airshipv1.NodeCount{Active: 1},
+		}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.nodes[Storage]"))
+	})
+
+	It("Admits node ports that are left to SIP to allocate", func() {
+		sip.Spec.Services.JumpHost[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{}
+
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Rejects a port range that only sets its end", func() {
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{End: 30002}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerWorker[0].nodePortRange.start"))
+	})
+
+	It("Rejects an inverted port range", func() {
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{Start: 30011, End: 30002}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerWorker[0].nodePortRange.end"))
+	})
+
+	It("Rejects an oversized port range", func() {
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{Start: 30002, End: 32000}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerWorker[0].nodePortRange"))
+	})
+
+	It("Rejects node ports outside the cluster node port range", func() {
+		sip.Spec.Services.JumpHost[0].NodePort = 22
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 40000
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{Start: 32760, End: 32770}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.jumpHost[0].nodePort",
+			"spec.services.loadBalancerControlPlane[0].nodePort",
+			"spec.services.loadBalancerWorker[0].nodePortRange.end",
+		))
+	})
+
+	It("Checks node ports against the configured node port range", func() {
+		defer func(nodePortRange airshipv1.PortRange) { airshipv1.NodePortRange = nodePortRange }(airshipv1.NodePortRange)
+		airshipv1.NodePortRange = airshipv1.PortRange{Start: 40000, End: 40099}
+		sip.Spec.Services.JumpHost[0].NodePort = 40000
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{Start: 40001, End: 40010}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerControlPlane[0].nodePort"))
+	})
+
+	It("Admits load balancer and external addresses", func() {
+		clusterIP := "10.96.0.20"
+		sip.Spec.Services.LoadBalancerControlPlane[0].ServiceType = corev1.ServiceTypeLoadBalancer
+		sip.Spec.Services.LoadBalancerControlPlane[0].LoadBalancerIP = "192.168.10.20"
+		sip.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeClusterIP
+		sip.Spec.Services.JumpHost[0].NodePort = 0
+		sip.Spec.Services.JumpHost[0].ClusterIP = &clusterIP
+		sip.Spec.Services.JumpHost[0].ExternalIPs = []string{"192.168.10.21"}
+
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Rejects node ports of ClusterIP services", func() {
+		sip.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeClusterIP
+		sip.Spec.Services.LoadBalancerWorker[0].ServiceType = corev1.ServiceTypeClusterIP
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.jumpHost[0].nodePort",
+			"spec.services.loadBalancerWorker[0].nodePortRange",
+		))
+	})
+
+	It("Rejects a load balancer IP of a service that is not a LoadBalancer", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].LoadBalancerIP = "192.168.10.20"
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerControlPlane[0].loadBalancerIP"))
+	})
+
+	It("Rejects invalid addresses", func() {
+		clusterIP := "10.96.0"
+		sip.Spec.Services.LoadBalancerControlPlane[0].ServiceType = corev1.ServiceTypeLoadBalancer
+		sip.Spec.Services.LoadBalancerControlPlane[0].LoadBalancerIP = "lb.example.com"
+		sip.Spec.Services.LoadBalancerWorker[0].ClusterIP = &clusterIP
+		sip.Spec.Services.JumpHost[0].ExternalIPs = []string{"192.168.10.21", "192.168.10.300"}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].loadBalancerIP",
+			"spec.services.loadBalancerWorker[0].clusterIP",
+			"spec.services.jumpHost[0].externalIPs[1]",
+		))
+	})
+
+	It("Rejects invalid node labels", func() {
+		sip.Spec.Services.JumpHost[0].NodeLabels = map[string]string{"node role": "infra"}
+		sip.Spec.Services.LoadBalancerWorker[0].NodeLabels = map[string]string{"role": "infra nodes"}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.jumpHost[0].nodeLabels",
+			"spec.services.loadBalancerWorker[0].nodeLabels",
+		))
+	})
+
+	It("Fills in the defaults of the ports of a control plane load balancer", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerControlPlane[0].Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443, NodePort: 30001, HealthCheck: airshipv1.HealthCheckHTTP, TLSCheck: true},
+			{Name: "konnectivity", FrontendPort: 8132},
+			{Name: "ingress", FrontendPort: 443, BackendPort: 30443, HealthCheck: airshipv1.HealthCheckNone},
+		}
+
+		sip.Default()
+
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].Ports).To(Equal([]airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443, BackendPort: 6443, NodePort: 30001,
+				HealthCheck: airshipv1.HealthCheckHTTP, HealthCheckPath: "/readyz", TLSCheck: true},
+			{Name: "konnectivity", FrontendPort: 8132, BackendPort: 8132, HealthCheck: airshipv1.HealthCheckTCP},
+			{Name: "ingress", FrontendPort: 443, BackendPort: 30443, HealthCheck: airshipv1.HealthCheckNone},
+		}))
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Forwards the API server port of a control plane load balancer that sets no ports", func() {
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].ForwardedPorts()).To(Equal([]airshipv1.LoadBalancerPort{{
+			Name:            "http",
+			FrontendPort:    airshipv1.ControlPlaneLoadBalancerPort,
+			BackendPort:     airshipv1.ControlPlaneLoadBalancerPort,
+			NodePort:        30001,
+			HealthCheck:     airshipv1.HealthCheckHTTP,
+			HealthCheckPath: airshipv1.DefaultHealthCheckPath,
+			TLSCheck:        true,
+		}}))
+	})
+
+	It("Checks the API server port of an NGINX control plane load balancer with TCP connections", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Engine = airshipv1.EngineNGINX
+
+		ports := sip.Spec.Services.LoadBalancerControlPlane[0].ForwardedPorts()
+		Expect(ports).To(HaveLen(1))
+		Expect(ports[0].HealthCheck).To(Equal(airshipv1.HealthCheckTCP))
+		Expect(ports[0].HealthCheckPath).To(BeEmpty())
+		Expect(ports[0].TLSCheck).To(BeFalse())
+	})
+
+	It("Rejects HTTP and TLS health checks of an NGINX control plane load balancer", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerControlPlane[0].Engine = airshipv1.EngineNGINX
+		sip.Spec.Services.LoadBalancerControlPlane[0].Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443, HealthCheck: airshipv1.HealthCheckHTTP, TLSCheck: true},
+			{Name: "konnectivity", FrontendPort: 8132, HealthCheck: airshipv1.HealthCheckTCP, TLSCheck: true},
+			{Name: "ingress", FrontendPort: 443, HealthCheck: airshipv1.HealthCheckTCP},
+		}
+
+		sip.Default()
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].ports[0].healthCheck",
+			"spec.services.loadBalancerControlPlane[0].ports[0].tlsCheck",
+			"spec.services.loadBalancerControlPlane[0].ports[1].tlsCheck",
+		))
+	})
+
+	It("Rejects invalid ports of a control plane load balancer", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443, NodePort: 30002, HealthCheckPath: "/readyz"},
+			{Name: "https", FrontendPort: 6443, NodePort: 30002},
+			{Name: "Konnectivity_Server", FrontendPort: 70000, BackendPort: -1,
+				HealthCheck: airshipv1.HealthCheckHTTP, HealthCheckPath: "readyz"},
+		}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].nodePort",
+			"spec.services.loadBalancerControlPlane[0].ports[0].healthCheckPath",
+			"spec.services.loadBalancerControlPlane[0].ports[1].name",
+			"spec.services.loadBalancerControlPlane[0].ports[1].frontendPort",
+			"spec.services.loadBalancerControlPlane[0].ports[1].nodePort",
+			"spec.services.loadBalancerControlPlane[0].ports[2].name",
+			"spec.services.loadBalancerControlPlane[0].ports[2].name",
+			"spec.services.loadBalancerControlPlane[0].ports[2].frontendPort",
+			"spec.services.loadBalancerControlPlane[0].ports[2].backendPort",
+			"spec.services.loadBalancerControlPlane[0].ports[2].healthCheckPath",
+		))
+	})
+
+	It("Rejects node ports of the ports of a ClusterIP control plane load balancer", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerControlPlane[0].ServiceType = corev1.ServiceTypeClusterIP
+		sip.Spec.Services.LoadBalancerControlPlane[0].Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443},
+			{Name: "konnectivity", FrontendPort: 8132, NodePort: 30002},
+		}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.loadBalancerControlPlane[0].ports[1].nodePort"))
+	})
+
+	It("Fills in the defaults of a virtual IP", func() {
+		virtualIP := "192.168.10.30"
+		sip.Spec.Services.LoadBalancerControlPlane[0].ClusterIP = &virtualIP
+		sip.Spec.Services.LoadBalancerControlPlane[0].VirtualIP = &airshipv1.VirtualIPOpts{
+			Interface:       "bond0",
+			VirtualRouterID: 51,
+		}
+
+		sip.Default()
+
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].VirtualIP).To(Equal(&airshipv1.VirtualIPOpts{
+			Interface:       "bond0",
+			VirtualRouterID: 51,
+			Image:           airshipv1.DefaultKeepalivedImage,
+		}))
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Requires the virtual router ID of a virtual IP", func() {
+		virtualIP := "192.168.10.30"
+		sip.Spec.Services.LoadBalancerControlPlane[0].ClusterIP = &virtualIP
+		sip.Spec.Services.LoadBalancerControlPlane[0].VirtualIP = &airshipv1.VirtualIPOpts{Interface: "bond0"}
+
+		sip.Default()
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].virtualIP.virtualRouterID"))
+	})
+
+	It("Rejects a virtual IP without an address or interface", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Replicas = -1
+		sip.Spec.Services.LoadBalancerControlPlane[0].VirtualIP = &airshipv1.VirtualIPOpts{VirtualRouterID: 256}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].replicas",
+			"spec.services.loadBalancerControlPlane[0].clusterIP",
+			"spec.services.loadBalancerControlPlane[0].virtualIP.interface",
+			"spec.services.loadBalancerControlPlane[0].virtualIP.virtualRouterID",
+		))
+	})
+
+	It("Rejects an engine of a jump host", func() {
+		sip.Spec.Services.JumpHost[0].Engine = airshipv1.EngineNGINX
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.jumpHost[0].engine"))
+	})
+
+	It("Fills in the default metrics port", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Metrics = &airshipv1.MetricsOpts{}
+		sip.Spec.Services.LoadBalancerWorker[0].Metrics = &airshipv1.MetricsOpts{Port: 9101}
+
+		sip.Default()
+
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].Metrics.Port).To(Equal(airshipv1.DefaultMetricsPort))
+		Expect(sip.Spec.Services.LoadBalancerWorker[0].Metrics.Port).To(Equal(9101))
+		Expect(sip.ValidateCreate()).To(Succeed())
+	})
+
+	It("Rejects metrics that cannot be exported", func() {
+		sip.Spec.Services.LoadBalancerControlPlane[0].Metrics = &airshipv1.MetricsOpts{
+			Port: airshipv1.ControlPlaneLoadBalancerPort,
+			ServiceMonitor: &airshipv1.ServiceMonitorOpts{
+				Labels: map[string]string{"release": "prometheus/operator"},
+			},
+		}
+		sip.Spec.Services.LoadBalancerWorker[0].Engine = airshipv1.EngineNGINX
+		sip.Spec.Services.LoadBalancerWorker[0].Metrics = &airshipv1.MetricsOpts{Port: 30100}
+		sip.Spec.Services.JumpHost[0].Metrics = &airshipv1.MetricsOpts{}
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf(
+			"spec.services.loadBalancerControlPlane[0].metrics.port",
+			"spec.services.loadBalancerControlPlane[0].metrics.serviceMonitor.labels",
+			"spec.services.loadBalancerWorker[0].metrics",
+			"spec.services.loadBalancerWorker[0].metrics.port",
+			"spec.services.jumpHost[0].metrics",
+		))
+	})
+
+	It("Rejects an invalid SSH authorized key", func() {
+		sip.Spec.Services.JumpHost[0].SSHAuthorizedKeys = append(sip.Spec.Services.JumpHost[0].SSHAuthorizedKeys,
+			"ssh-rsa not-a-key")
+
+		Expect(causes(sip.ValidateCreate())).To(ConsistOf("spec.services.jumpHost[0].sshAuthorizedKeys[2]"))
+	})
+
+	It("Rejects a jump host without node SSH private keys", func() {
+		sip.Spec.Services.JumpHost[0].NodeSSHPrivateKeys = ""
+
+		Expect(causes(sip.ValidateUpdate(sip.DeepCopy()))).To(
+			ConsistOf("spec.services.jumpHost[0].nodeSSHPrivateKeys"))
+	})
+
+	It("Admits the deletion of an invalid SIPCluster", func() {
+		sip.Spec.Services.JumpHost[0].NodeSSHPrivateKeys = ""
+
+		Expect(sip.ValidateDelete()).To(Succeed())
+	})
+})
@@ -0,0 +1,124 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// SIPInventoryList contains a list of SIPInventory
+type SIPInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SIPInventory `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+
+// SIPInventory is the Schema for the sipinventories API. It reports the free and claimed BMHs of each host group,
+// so that the capacity left for new SIPClusters can be read without counting hosts by hand.
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type SIPInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SIPInventorySpec   `json:"spec,omitempty"`
+	Status SIPInventoryStatus `json:"status,omitempty"`
+}
+
+// SIPInventorySpec defines the host groups that a SIPInventory reports on
+type SIPInventorySpec struct {
+	// Groups defines the host groups to report on, e.g. one per node flavor.
+	Groups []InventoryGroup `json:"groups,omitempty"`
+
+	// TopologyKeys are the BMH labels by whose values the hosts of each group are broken down, e.g. the rack label
+	// used as the topologyKey of SIPCluster node sets.
+	TopologyKeys []string `json:"topologyKeys,omitempty"`
+}
+
+// InventoryGroup is a named group of BMHs.
+type InventoryGroup struct {
+	// Name identifies the group in the status.
+	Name string `json:"name"`
+	// LabelSelector is the BMH label selector of the group, in the same form as the labelSelector of a SIPCluster
+	// node set. An empty selector matches every BMH.
+	LabelSelector metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// SIPInventoryStatus defines the observed state of SIPInventory
+type SIPInventoryStatus struct {
+	// Groups reports the hosts of each group in the spec.
+	Groups []InventoryGroupStatus `json:"groups,omitempty"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// HostCounts holds the number of free and claimed BMHs in a set of hosts.
+type HostCounts struct {
+	// Free is the number of hosts that are not claimed by a SIPCluster and that SIP can schedule.
+	Free int `json:"free"`
+	// Claimed is the number of hosts that are claimed by a SIPCluster.
+	Claimed int `json:"claimed"`
+}
+
+// InventoryGroupStatus reports the hosts of a group.
+type InventoryGroupStatus struct {
+	// Name is the name of the group in the spec.
+	Name       string `json:"name"`
+	HostCounts `json:",inline"`
+	// Roles holds the number of claimed hosts of the group by the role they were scheduled for.
+	Roles map[BMHRole]int `json:"roles,omitempty"`
+	// TopologyDomains breaks down the hosts of the group by the values of the topology keys in the spec.
+	TopologyDomains []TopologyDomainStatus `json:"topologyDomains,omitempty"`
+	// ClaimedHosts lists the claimed hosts of the group and the SIPClusters that own them.
+	ClaimedHosts []ClaimedHost `json:"claimedHosts,omitempty"`
+}
+
+// TopologyDomainStatus reports the hosts of a group that share a topology domain.
+type TopologyDomainStatus struct {
+	// Key is the topology key.
+	Key string `json:"key"`
+	// Value is the value of the topology key shared by the hosts. Hosts without the label are reported with an
+	// empty value.
+	Value      string `json:"value"`
+	HostCounts `json:",inline"`
+}
+
+// ClaimedHost identifies a BMH that is claimed by a SIPCluster.
+type ClaimedHost struct {
+	// Name is the name of the BMH.
+	Name string `json:"name"`
+	// Namespace is the namespace of the BMH.
+	Namespace string `json:"namespace"`
+	// Role is the role that the BMH was scheduled for.
+	Role BMHRole `json:"role,omitempty"`
+	// SIPCluster is the namespace/name of the SIPCluster that owns the BMH.
+	SIPCluster string `json:"sipCluster"`
+}
+
+const (
+	// ReasonTypeUnableToCountHosts indicates that a resource has a specified condition because SIP was unable to
+	// count the BMHs of the SIPInventory.
+	ReasonTypeUnableToCountHosts string = "UnableToCountHosts"
+)
+
+func init() {
+	SchemeBuilder.Register(&SIPInventory{}, &SIPInventoryList{})
+}
@@ -0,0 +1,156 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bmh
+
+import (
+	"context"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+const (
+	// SipAddressSourceAnnotation selects where SIP resolves the service addresses of a BMH from. When the annotation
+	// is not set, addresses are read from the BMH's Network Data Secret.
+	SipAddressSourceAnnotation = BaseAirshipSelector + "/" + "address-source"
+
+	// AddressSourceNetworkData resolves addresses from the Secret referenced by the BMH's networkData field.
+	AddressSourceNetworkData = "network-data"
+
+	// AddressSourceIPAM resolves addresses from Metal3 IP Address Manager IPClaims. SIP expects one IPClaim per
+	// network, named <BMH name>-<IPPool name> in the namespace of the BMH. The IPPool name is the network ID
+	// matched against the nodeInterfaceId of the SIPCluster services.
+	AddressSourceIPAM = "ipam"
+)
+
+// Key used to retrieve the network data from the BMH Network Data Secret
+const keyNetworkData = "networkData"
+
+var (
+	ipClaimListGVK = schema.GroupVersionKind{Group: "ipam.metal3.io", Version: "v1alpha1", Kind: "IPClaimList"}
+	ipAddressGVK   = schema.GroupVersionKind{Group: "ipam.metal3.io", Version: "v1alpha1", Kind: "IPAddress"}
+)
+
+// AddressSource resolves the networks of a BMH, and the IP address the BMH holds on each of them.
+type AddressSource interface {
+	NetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error)
+}
+
+// NewAddressSource returns the AddressSource selected by the BMH's address source annotation.
+func NewAddressSource(bmh metal3.BareMetalHost, c client.Client) AddressSource {
+	if usesIPAM(bmh) {
+		return ipamAddressSource{client: c}
+	}
+	return networkDataAddressSource{client: c}
+}
+
+func usesIPAM(bmh metal3.BareMetalHost) bool {
+	return bmh.GetAnnotations()[SipAddressSourceAnnotation] == AddressSourceIPAM
+}
+
+func addressSourceName(bmh metal3.BareMetalHost) string {
+	if usesIPAM(bmh) {
+		return AddressSourceIPAM
+	}
+	return AddressSourceNetworkData
+}
+
+// networkDataAddressSource reads addresses from the BMH's Network Data Secret.
+type networkDataAddressSource struct {
+	client client.Client
+}
+
+func (s networkDataAddressSource) NetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error) {
+	if bmh.Spec.NetworkData == nil {
+		return nil, &ErrorNetworkDataNotFound{BMH: bmh}
+	}
+
+	networkDataSecret := &corev1.Secret{}
+	err := s.client.Get(context.Background(), client.ObjectKey{
+		Namespace: bmh.Spec.NetworkData.Namespace,
+		Name:      bmh.Spec.NetworkData.Name,
+	}, networkDataSecret)
+	if err != nil {
+		return nil, err
+	}
+	return parseNetworkData(networkDataSecret)
+}
+
+// parseNetworkData reads the networks from a BMH Network Data Secret.
+func parseNetworkData(secret *corev1.Secret) (*airshipv1.NetworkData, error) {
+	netData := &airshipv1.NetworkData{}
+	err := yaml.Unmarshal(secret.Data[keyNetworkData], netData)
+	if err != nil {
+		return nil, err
+	}
+	return netData, nil
+}
+
+// ipamAddressSource reads addresses from the IPAddresses allocated to the BMH's IPClaims.
+type ipamAddressSource struct {
+	client client.Client
+}
+
+func (s ipamAddressSource) NetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error) {
+	claims := &unstructured.UnstructuredList{}
+	claims.SetGroupVersionKind(ipClaimListGVK)
+	err := s.client.List(context.Background(), claims, client.InNamespace(bmh.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	netData := &airshipv1.NetworkData{}
+	for _, claim := range claims.Items {
+		pool, _, err := unstructured.NestedString(claim.Object, "spec", "pool", "name")
+		if err != nil || claim.GetName() != bmh.Name+"-"+pool {
+			continue
+		}
+
+		// Claims that have not been fulfilled by IPAM yet are skipped; the missing network is reported once the
+		// service addresses are matched against it.
+		addressName, found, err := unstructured.NestedString(claim.Object, "status", "address", "name")
+		if err != nil || !found || addressName == "" {
+			continue
+		}
+
+		address := &unstructured.Unstructured{}
+		address.SetGroupVersionKind(ipAddressGVK)
+		err = s.client.Get(context.Background(), client.ObjectKey{
+			Namespace: bmh.Namespace,
+			Name:      addressName,
+		}, address)
+		if err != nil {
+			return nil, err
+		}
+
+		ip, _, err := unstructured.NestedString(address.Object, "spec", "address")
+		if err != nil {
+			return nil, err
+		}
+		netData.OpenstackNetworks = append(netData.OpenstackNetworks, airshipv1.OpenstackNetwork{
+			ID: pool,
+			IP: ip,
+		})
+	}
+	return netData, nil
+}
@@ -19,11 +19,14 @@ package bmh
 import (
 	"context"
 	"fmt"
-
+	"io"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	airshipv1 "sipcluster/pkg/api/v1"
-	v1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/metrics"
 
 	"github.com/go-logr/logr"
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -33,10 +36,14 @@ import (
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	kerror "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/yaml"
 )
 
+// DebugOutput is where the scheduler prints its debug output. Commands that print their results on stdout point it
+// elsewhere.
+var DebugOutput io.Writer = os.Stdout
+
 type ScheduledState string
 
 // Possible Node or BMH roles  for a Tenant
@@ -99,7 +106,8 @@ func (m *Machine) String() string {
 
 func NewMachine(bmh metal3.BareMetalHost, nodeRole airshipv1.BMHRole, schedState ScheduledState) (m *Machine, e error) {
 	// Add logic to check if required fields exist.
-	if bmh.Spec.NetworkData == nil {
+	// Hosts managed by Metal3 IPAM get their addresses from IPClaims instead of NetworkData.
+	if bmh.Spec.NetworkData == nil && !usesIPAM(bmh) {
 		return nil, &ErrorNetworkDataNotFound{BMH: bmh}
 	}
 	return &Machine{
@@ -112,6 +120,40 @@ func NewMachine(bmh metal3.BareMetalHost, nodeRole airshipv1.BMHRole, schedState
 	}, nil
 }
 
+// unavailableStates are the provisioning states of hosts that are in use, being handed over or failed.
+var unavailableStates = map[metal3.ProvisioningState]bool{
+	metal3.StateRegistrationError:     true,
+	metal3.StateProvisioning:          true,
+	metal3.StateProvisioningError:     true,
+	metal3.StateProvisioned:           true,
+	metal3.StateExternallyProvisioned: true,
+	metal3.StateDeprovisioning:        true,
+	metal3.StatePowerManagementError:  true,
+	metal3.StateDeleting:              true,
+}
+
+// checkAvailable returns an ErrHostUnavailable for a host that is being deleted, has an error or is in one of the
+// unavailableStates. The scheduler skips such hosts, and they are not counted as free capacity.
+func checkAvailable(bmh metal3.BareMetalHost) error {
+	switch {
+	case !bmh.DeletionTimestamp.IsZero():
+		return ErrHostUnavailable{BMH: bmh.Name, Reason: "it is being deleted"}
+	case bmh.HasError():
+		return ErrHostUnavailable{BMH: bmh.Name, Reason: fmt.Sprintf("%s: %s", bmh.Status.ErrorType,
+			bmh.Status.ErrorMessage)}
+	case unavailableStates[bmh.Status.Provisioning.State]:
+		return ErrHostUnavailable{BMH: bmh.Name, Reason: fmt.Sprintf("it is in the %q provisioning state",
+			bmh.Status.Provisioning.State)}
+	}
+	return nil
+}
+
+// schedulable returns whether the scheduler accepts a host that is not scheduled yet: the host must be available,
+// and must meet the requirements of NewMachine.
+func schedulable(bmh metal3.BareMetalHost) bool {
+	return checkAvailable(bmh) == nil && (bmh.Spec.NetworkData != nil || usesIPAM(bmh))
+}
+
 type MachineData struct {
 	// Collect all IP's for the interfaces defined
 	// In the list of Services
@@ -128,6 +170,11 @@ type MachineList struct {
 	// Keep track  of how many we have mark for scheduled.
 	ReadyForScheduleCount map[airshipv1.BMHRole]int
 	Log                   logr.Logger
+	// Recorder records events about the BMHs on their SIPCluster. No events are recorded when it is nil.
+	Recorder record.EventRecorder
+	// excluded holds the names of the BMHs that have been counted as excluded, so that a BMH that is considered by
+	// several node sets or scheduling passes is only counted once.
+	excluded map[string]bool
 }
 
 func (ml *MachineList) hasMachine(bmh metal3.BareMetalHost) bool {
@@ -143,20 +190,21 @@ func (ml *MachineList) String() string {
 	return sb.String()
 }
 
-func (ml *MachineList) Schedule(sip airshipv1.SIPCluster, c client.Client) error {
+// Schedule selects the BMHs from the inventory that satisfy the node sets of the SIPCluster.
+func (ml *MachineList) Schedule(sip airshipv1.SIPCluster, inv Inventory) error {
 	ml.Log.Info("starting scheduling of BaremetalHosts")
 
 	// Initialize the Target list
 	ml.init(sip.Spec.Nodes)
 
 	// IDentify BMH's that meet the appropriate selction criteria
-	bmhList, err := ml.getBMHs(c)
+	bmhList, err := ml.getBMHs(inv)
 	if err != nil {
 		return err
 	}
 
 	//  Identify and Select the BMH I actually will use
-	err = ml.identifyNodes(sip, bmhList, c)
+	err = ml.identifyNodes(sip, bmhList, inv)
 	if err != nil {
 		return err
 	}
@@ -174,18 +222,19 @@ func (ml *MachineList) init(nodes map[airshipv1.BMHRole]airshipv1.NodeSet) {
 		mlSize := 0
 		mlNodeTypes := 0
 		for _, nodeCfg := range nodes {
+			if nodeCfg.Count == nil {
+				continue
+			}
 			mlSize = mlSize + nodeCfg.Count.Active + nodeCfg.Count.Standby
 			mlNodeTypes++
 		}
-		fmt.Printf("Schedule.init mlSize:%d\n", mlSize)
+		fmt.Fprintf(DebugOutput, "Schedule.init mlSize:%d\n", mlSize)
 		ml.ReadyForScheduleCount = make(map[airshipv1.BMHRole]int, mlNodeTypes)
 		ml.Machines = make(map[string]*Machine, 0)
 	}
 }
 
-func (ml *MachineList) getBMHs(c client.Client) (*metal3.BareMetalHostList, error) {
-	bmhList := &metal3.BareMetalHostList{}
-
+func (ml *MachineList) getBMHs(inv Inventory) ([]metal3.BareMetalHost, error) {
 	// Select BMH not yet labeled as scheduled by SIP
 	unscheduledSelector := labels.NewSelector()
 	r, err := labels.NewRequirement(SipClusterNameLabel, selection.DoesNotExist, nil)
@@ -194,32 +243,32 @@ func (ml *MachineList) getBMHs(c client.Client) (*metal3.BareMetalHostList, erro
 	}
 
 	ml.Log.Info("Getting all available BaremetalHosts that are not scheduled")
-	err = c.List(context.Background(), bmhList, client.MatchingLabelsSelector{Selector: unscheduledSelector})
+	bmhList, err := inv.ListHosts(unscheduledSelector)
 	if err != nil {
 		ml.Log.Info("Received an error while getting BaremetalHost list", "error", err.Error())
 		return bmhList, err
 	}
-	ml.Log.Info("Got a list of hosts", "BaremetalHostCount", len(bmhList.Items))
-	if len(bmhList.Items) > 0 {
+	ml.Log.Info("Got a list of hosts", "BaremetalHostCount", len(bmhList))
+	if len(bmhList) > 0 {
 		return bmhList, nil
 	}
-	return bmhList, fmt.Errorf("Unable to identify BMH available for scheduling. Selecting  %v ", unscheduledSelector)
+	return bmhList, ErrNoHostsAvailable{Selector: unscheduledSelector}
 }
 
 func (ml *MachineList) identifyNodes(sip airshipv1.SIPCluster,
-	bmhList *metal3.BareMetalHostList, c client.Client) error {
+	bmhList []metal3.BareMetalHost, inv Inventory) error {
 	// If using the SIP Sheduled label, we now have a list of BMH;'s
 	// that are not scheduled
 	// Next I need to apply the constraints
 	ml.Log.Info("Trying to identify BaremetalHosts that match scheduling parameters",
-		"initial BMH count", len(bmhList.Items))
+		"initial BMH count", len(bmhList))
 	for nodeRole, nodeCfg := range sip.Spec.Nodes {
 		logger := ml.Log.WithValues("role", nodeRole) //nolint:govet
 		ml.ReadyForScheduleCount[nodeRole] = 0
 		logger.Info("Getting host constraints")
 		scheduleSetMap := ml.initScheduleMaps(nodeRole, nodeCfg.TopologyKey)
 		logger.Info("Matching hosts against constraints")
-		err := ml.scheduleIt(nodeRole, nodeCfg, bmhList, scheduleSetMap, c, sip)
+		err := ml.scheduleIt(nodeRole, nodeCfg, bmhList, scheduleSetMap, inv, sip)
 		if err != nil {
 			return err
 		}
@@ -240,15 +289,13 @@ func (ml *MachineList) initScheduleMaps(role airshipv1.BMHRole,
 }
 
 func (ml *MachineList) countScheduledAndTobeScheduled(nodeRole airshipv1.BMHRole,
-	c client.Client, sip airshipv1.SIPCluster) int {
-	bmhList := &metal3.BareMetalHostList{}
-
+	inv Inventory, sip airshipv1.SIPCluster) int {
 	scheduleLabels := GetClusterLabels(sip)
 	scheduleLabels[SipNodeTypeLabel] = string(nodeRole)
 
 	logger := ml.Log.WithValues("role", nodeRole)
 	logger.Info("Getting list of BaremetalHost already scheduled for SIP cluster from kubernetes")
-	err := c.List(context.Background(), bmhList, client.MatchingLabels(scheduleLabels))
+	bmhList, err := inv.ListHosts(labels.SelectorFromSet(scheduleLabels))
 	if err != nil {
 		logger.Info("Received error when getting BaremetalHosts", "error", err.Error())
 		return 0
@@ -256,8 +303,8 @@ func (ml *MachineList) countScheduledAndTobeScheduled(nodeRole airshipv1.BMHRole
 
 	// TODO Update the Machine List
 	// With what is already there.
-	logger.Info("Got already scheduled BaremetalHosts from kubernetes", "count", len(bmhList.Items))
-	for _, bmh := range bmhList.Items {
+	logger.Info("Got already scheduled BaremetalHosts from kubernetes", "count", len(bmhList))
+	for _, bmh := range bmhList {
 		logger := logger.WithValues("BMH name", bmh.GetName()) //nolint:govet
 		readyScheduled := !ml.hasMachine(bmh)
 		logger.Info("Checking if BMH is already marked to be scheduled", "ready to be scheduled", readyScheduled)
@@ -281,15 +328,19 @@ func (ml *MachineList) countScheduledAndTobeScheduled(nodeRole airshipv1.BMHRole
 }
 
 func (ml *MachineList) scheduleIt(nodeRole airshipv1.BMHRole, nodeCfg airshipv1.NodeSet,
-	bmList *metal3.BareMetalHostList, scheduleSet *ScheduleSet,
-	c client.Client, sip airshipv1.SIPCluster) error {
+	bmList []metal3.BareMetalHost, scheduleSet *ScheduleSet,
+	inv Inventory, sip airshipv1.SIPCluster) error {
 	logger := ml.Log.WithValues("role", nodeRole)
 	validBmh := true
 	// Count the expectations stated in the CR
 	// 	Reduce from the list of BMH's already scheduled and  labeled with the Cluster Name
 	// 	Reduce from the number of Machines I have identified  already to be Labeled
+	// A node set without a count has no nodes to schedule
+	if nodeCfg.Count == nil {
+		return nil
+	}
 	totalNodes := nodeCfg.Count.Active + nodeCfg.Count.Standby
-	nodeTarget := totalNodes - ml.countScheduledAndTobeScheduled(nodeRole, c, sip)
+	nodeTarget := totalNodes - ml.countScheduledAndTobeScheduled(nodeRole, inv, sip)
 
 	logger.Info("BMH count that need to be scheduled for SIP cluster discouting nodes ready to be scheduled",
 		"BMH count to be scheduled", nodeTarget)
@@ -298,10 +349,14 @@ func (ml *MachineList) scheduleIt(nodeRole airshipv1.BMHRole, nodeCfg airshipv1.
 		return nil
 	}
 	logger.Info("Checking list of BMH initially received as not scheduled anywhere yet")
-	for _, bmh := range bmList.Items {
+	for _, bmh := range bmList {
 		logger := logger.WithValues("BaremetalHost Name", bmh.GetName()) //nolint:govet
 
 		if !ml.hasMachine(bmh) {
+			if err := checkAvailable(bmh); err != nil {
+				logger.Info("Skipping BMH host as it is unavailable", "error", err.Error())
+				continue
+			}
 			logger.Info("BaremetalHost not yet marked as ready to be scheduled")
 			topologyKey := nodeCfg.TopologyKey
 			// Do I care about this constraint
@@ -322,6 +377,7 @@ func (ml *MachineList) scheduleIt(nodeRole airshipv1.BMHRole, nodeCfg airshipv1.
 					// If its in the list already for the constraint , theen this bmh is disqualified. Skip it
 					if scheduleSet.Exists(topologyDomain) {
 						logger.Info("Topology domain has already been scheduled to, skipping it")
+						ml.countExclusion(bmh, metrics.ExclusionTopologyDomain)
 						continue
 					} else {
 						scheduleSet.Add(topologyDomain)
@@ -335,10 +391,13 @@ func (ml *MachineList) scheduleIt(nodeRole airshipv1.BMHRole, nodeCfg airshipv1.
 				m, err := NewMachine(bmh, nodeRole, ToBeScheduled)
 				if err != nil {
 					logger.Info("Skipping BMH host as it did not meet creation requirements", "error", err.Error())
+					ml.recordRejection(sip, bmh, nodeRole, metrics.ExclusionNetworkData, err)
 					continue
 				}
 				ml.Machines[bmh.ObjectMeta.Name] = m
 				ml.ReadyForScheduleCount[nodeRole]++
+				ml.recordEvent(sip, corev1.EventTypeNormal, ReasonHostSelected, "Selected BMH %s/%s for the %s node set",
+					bmh.Namespace, bmh.Name, nodeRole)
 				// TODO Probable should remove the bmh from the
 				// list so if there are other node targets they
 				// dont even take it into account
@@ -365,29 +424,87 @@ func (ml *MachineList) scheduleIt(nodeRole airshipv1.BMHRole, nodeCfg airshipv1.
 	return nil
 }
 
+// Restore selects the BMHs recorded in the status of a SIPCluster again, instead of scheduling new ones. It fails if
+// a recorded BMH no longer exists, no longer matches its node set or has been claimed by another SIPCluster, or if the
+// recorded BMHs do not fill the node sets of the SIPCluster.
+func (ml *MachineList) Restore(sip airshipv1.SIPCluster, nodes []airshipv1.NodeStatus, inv Inventory) error {
+	ml.init(sip.Spec.Nodes)
+
+	bmhList, err := inv.ListHosts(labels.Everything())
+	if err != nil {
+		return err
+	}
+	hosts := make(map[types.NamespacedName]metal3.BareMetalHost, len(bmhList))
+	for _, bmh := range bmhList {
+		hosts[types.NamespacedName{Namespace: bmh.Namespace, Name: bmh.Name}] = bmh
+	}
+
+	for _, node := range nodes {
+		bmh, ok := hosts[types.NamespacedName{Namespace: node.Namespace, Name: node.Name}]
+		if !ok {
+			return ErrHostNotRestorable{Host: node.Name, Reason: "the BMH does not exist"}
+		}
+		nodeSet, ok := sip.Spec.Nodes[node.Role]
+		if !ok {
+			return ErrHostNotRestorable{Host: node.Name, Reason: fmt.Sprintf("the %s node set was removed", node.Role)}
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&nodeSet.LabelSelector)
+		if err != nil {
+			return err
+		}
+		if !selector.Matches(labels.Set(bmh.Labels)) {
+			return ErrHostNotRestorable{Host: node.Name, Reason: "the BMH no longer matches its node set"}
+		}
+
+		schedState := ToBeScheduled
+		if name, ok := bmh.Labels[SipClusterNameLabel]; ok {
+			if name != sip.Name || bmh.Labels[SipClusterNamespaceLabel] != sip.Namespace {
+				return ErrHostNotRestorable{Host: node.Name, Reason: "the BMH is scheduled to another SIPCluster"}
+			}
+			schedState = Scheduled
+		}
+
+		m, err := NewMachine(bmh, node.Role, schedState)
+		if err != nil {
+			return err
+		}
+		ml.Machines[bmh.Name] = m
+		ml.ReadyForScheduleCount[node.Role]++
+	}
+
+	for nodeRole, nodeSet := range sip.Spec.Nodes {
+		if nodeSet.Count == nil {
+			continue
+		}
+		if ml.ReadyForScheduleCount[nodeRole] != nodeSet.Count.Active+nodeSet.Count.Standby {
+			return ErrorUnableToFullySchedule{
+				TargetNode:          nodeRole,
+				TargetLabelSelector: nodeSet.LabelSelector,
+			}
+		}
+	}
+	return nil
+}
+
 // ExtrapolateServiceAddresses extracts the IP addresses of each network interface mapped to a service in the SIPCluster
-// CR by inspecting each BMH's Network Data Secret.
-func (ml *MachineList) ExtrapolateServiceAddresses(sip airshipv1.SIPCluster, c client.Client) error {
+// CR by inspecting each BMH's address source, either its Network Data Secret or its Metal3 IPAM claims.
+func (ml *MachineList) ExtrapolateServiceAddresses(sip airshipv1.SIPCluster, inv Inventory) error {
 	// NOTE: At this point in the scheduling algorithm, the list of Machines in the MachineList each have BMH
 	// objects that meet the SIPCluster CR topology and role constraints.
 
 	var extrapolateErrs error
 	for _, machine := range ml.Machines {
-		// Skip machines whose service addresses have been extracted
-		if len(machine.Data.IPOnInterface) > 0 {
+		// Skip machines whose service addresses have been extracted, or that have been rejected already
+		if len(machine.Data.IPOnInterface) > 0 || machine.ScheduleStatus == UnableToSchedule {
 			continue
 		}
 
-		// Retrieve BMH Network Data Secret
-		networkDataSecret := &corev1.Secret{}
-		err := c.Get(context.Background(), client.ObjectKey{
-			Namespace: machine.BMH.Spec.NetworkData.Namespace,
-			Name:      machine.BMH.Spec.NetworkData.Name,
-		}, networkDataSecret)
+		// Retrieve the BMH networks from its address source
+		netData, err := inv.GetNetworkData(machine.BMH)
 		if err != nil {
-			ml.Log.Error(err, "unable to retrieve BMH Network Data Secret", "BMH", machine.BMH.Name,
-				"Secret", machine.BMH.Spec.NetworkData.Name,
-				"Secret Namespace", machine.BMH.Spec.NetworkData.Namespace)
+			ml.Log.Error(err, "unable to retrieve BMH network data", "BMH", machine.BMH.Name,
+				"address source", addressSourceName(machine.BMH))
+			ml.recordRejection(sip, machine.BMH, machine.BMHRole, metrics.ExclusionNetworkData, err)
 
 			machine.ScheduleStatus = UnableToSchedule
 			ml.ReadyForScheduleCount[machine.BMHRole]--
@@ -397,11 +514,11 @@ func (ml *MachineList) ExtrapolateServiceAddresses(sip airshipv1.SIPCluster, c c
 		}
 
 		// Parse the interface IP addresses from the BMH's Network Data
-		err = ml.getIP(machine, networkDataSecret, sip.Spec.Services)
+		err = ml.getIP(machine, netData, sip.Spec.Services)
 		if err != nil {
-			ml.Log.Error(err, "unable to parse BMH Network Data Secret", "BMH", machine.BMH.Name,
-				"Secret", machine.BMH.Spec.NetworkData.Name,
-				"Secret Namespace", machine.BMH.Spec.NetworkData.Namespace)
+			ml.Log.Error(err, "unable to parse BMH network data", "BMH", machine.BMH.Name,
+				"address source", addressSourceName(machine.BMH))
+			ml.recordRejection(sip, machine.BMH, machine.BMHRole, metrics.ExclusionNetworkData, err)
 
 			machine.ScheduleStatus = UnableToSchedule
 			ml.ReadyForScheduleCount[machine.BMHRole]--
@@ -413,40 +530,34 @@ func (ml *MachineList) ExtrapolateServiceAddresses(sip airshipv1.SIPCluster, c c
 }
 
 // ExtrapolateBMCAuth extracts the BMC authentication information in each BMH's BMC Credentials Secret.
-func (ml *MachineList) ExtrapolateBMCAuth(sip airshipv1.SIPCluster, c client.Client) error {
+func (ml *MachineList) ExtrapolateBMCAuth(sip airshipv1.SIPCluster, inv Inventory) error {
 	// NOTE: At this point in the scheduling algorithm, the list of Machines in the MachineList each have BMH
 	// objects that meet the SIPCluster CR topology and role constraints.
 
 	var extrapolateErrs error
 	for _, machine := range ml.Machines {
-		// Retrieve BMC credentials Secret
-		bmcCredsSecret := &corev1.Secret{}
-		err := c.Get(context.Background(), client.ObjectKey{
-			Namespace: machine.BMH.Namespace,
-			Name:      machine.BMH.Spec.BMC.CredentialsName,
-		}, bmcCredsSecret)
-		if err != nil {
-			ml.Log.Error(err, "unable to retrieve BMH BMC credentials Secret", "BMH", machine.BMH.Name,
-				"Secret", machine.BMH.Spec.BMC.CredentialsName,
-				"Secret Namespace", machine.BMH.Namespace)
-			machine.ScheduleStatus = UnableToSchedule
-			ml.ReadyForScheduleCount[machine.BMHRole]--
-			extrapolateErrs = kerror.NewAggregate([]error{extrapolateErrs, err})
-
+		// Skip machines that have been rejected already
+		if machine.ScheduleStatus == UnableToSchedule {
 			continue
 		}
 
-		// Parse BMC credentials from Secret
-		err = ml.getMangementCredentials(machine, bmcCredsSecret)
+		// Retrieve and parse the BMC credentials Secret
+		username, password, err := inv.GetBMCCredentials(machine.BMH)
 		if err != nil {
-			ml.Log.Error(err, "unable to parse BMH BMC credentials Secret", "BMH", machine.BMH.Name,
+			ml.Log.Error(err, "unable to retrieve BMH BMC credentials", "BMH", machine.BMH.Name,
 				"Secret", machine.BMH.Spec.BMC.CredentialsName,
 				"Secret Namespace", machine.BMH.Namespace)
+			ml.recordRejection(sip, machine.BMH, machine.BMHRole, metrics.ExclusionBMCCredentials, err)
 
 			machine.ScheduleStatus = UnableToSchedule
 			ml.ReadyForScheduleCount[machine.BMHRole]--
 			extrapolateErrs = kerror.NewAggregate([]error{extrapolateErrs, err})
+
+			continue
 		}
+
+		machine.Data.BMCUsername = username
+		machine.Data.BMCPassword = password
 	}
 
 	return extrapolateErrs
@@ -600,16 +711,10 @@ func (ml *MachineList) ExtrapolateBMCAuth(sip airshipv1.SIPCluster, c client.Cli
 }
 ***/
 
-func (ml *MachineList) getIP(machine *Machine, networkDataSecret *corev1.Secret,
+func (ml *MachineList) getIP(machine *Machine, netData *airshipv1.NetworkData,
 	services airshipv1.SIPClusterServices) error {
-	// Now I have the Secret
+	// Now I have the Network Data
 	// Lets find the IP's for all Interfaces defined in Cfg
-
-	netData := &v1.NetworkData{}
-	err := yaml.Unmarshal(networkDataSecret.Data["networkData"], netData)
-	if err != nil {
-		return err
-	}
 	foundIP := false
 	for _, svcCfg := range services.GetAll() {
 		// Did I already find the IP for these interface
@@ -632,20 +737,18 @@ func (ml *MachineList) getIP(machine *Machine, networkDataSecret *corev1.Secret,
 }
 
 // getManagementCredentials retrieves BMC credentials from a Kubernetes secret.
-func (ml *MachineList) getMangementCredentials(machine *Machine, secret *corev1.Secret) error {
+func getManagementCredentials(secret *corev1.Secret) (string, string, error) {
 	username, exists := secret.Data[keyBMCUsername]
 	if !exists {
-		return ErrMalformedManagementCredentials{SecretName: secret.Name}
+		return "", "", ErrMalformedManagementCredentials{SecretName: secret.Name}
 	}
-	machine.Data.BMCUsername = string(username)
 
 	password, exists := secret.Data[keyBMCPassword]
 	if !exists {
-		return ErrMalformedManagementCredentials{SecretName: secret.Name}
+		return "", "", ErrMalformedManagementCredentials{SecretName: secret.Name}
 	}
-	machine.Data.BMCPassword = string(password)
 
-	return nil
+	return string(username), string(password), nil
 }
 
 // ScheduleSet is a simple object to encapsulate data that helps our poor man scheduler
@@ -670,7 +773,7 @@ func (ss *ScheduleSet) Add(labelValue string) {
 	ss.set[labelValue] = true
 }
 func (ss *ScheduleSet) GetLabels(labels labels.Labels, labelSelector *metav1.LabelSelector) (string, bool, error) {
-	fmt.Printf("Schedule.scheduleIt.GetLabels labels:%v, labelSelector:%s\n", labels, labelSelector)
+	fmt.Fprintf(DebugOutput, "Schedule.scheduleIt.GetLabels labels:%v, labelSelector:%s\n", labels, labelSelector)
 
 	match := false
 	if labels == nil {
@@ -685,11 +788,11 @@ func (ss *ScheduleSet) GetLabels(labels labels.Labels, labelSelector *metav1.Lab
 
 // ApplyLabels adds the appropriate labels to the BMHs that are ready to be scheduled
 func (ml *MachineList) ApplyLabels(sip airshipv1.SIPCluster, c client.Client) error {
-	fmt.Printf("ApplyLabels %s size:%d\n", ml.String(), len(ml.Machines))
+	ml.Log.Info("applying labels to BMHs", "count", len(ml.Machines))
 	for _, machine := range ml.Machines {
 		if machine.ScheduleStatus == ToBeScheduled {
 			bmh := &machine.BMH
-			fmt.Printf("ApplyLabels bmh.ObjectMeta.Name:%s\n", bmh.ObjectMeta.Name)
+			ml.Log.Info("applying labels to BMH", "BMH", bmh.ObjectMeta.Name)
 			for k, v := range GetClusterLabels(sip) {
 				bmh.Labels[k] = v
 			}
@@ -700,7 +803,7 @@ func (ml *MachineList) ApplyLabels(sip airshipv1.SIPCluster, c client.Client) er
 			// Allow it  to continue.
 			err := c.Update(context.Background(), bmh)
 			if err != nil {
-				fmt.Printf("ApplyLabel bmh:%s err:%v\n", bmh.ObjectMeta.Name, err)
+				ml.Log.Error(err, "unable to apply labels to BMH", "BMH", bmh.ObjectMeta.Name)
 				return err
 			}
 		}
@@ -708,40 +811,150 @@ func (ml *MachineList) ApplyLabels(sip airshipv1.SIPCluster, c client.Client) er
 	return nil
 }
 
-// RemoveLabels removes sip related labels
-func (ml *MachineList) RemoveLabels(c client.Client) error {
-	fmt.Printf("RemoveLabels %s size:%d\n", ml.String(), len(ml.Machines))
+// NodeStatus reports the machines that are scheduled to the SIPCluster, ordered by role and name. The scheduler does
+// not tell active and standby machines apart, so the first machines of each role by name fill the active count of
+// their node set and the rest are reported as standby.
+func (ml *MachineList) NodeStatus(sip airshipv1.SIPCluster) []airshipv1.NodeStatus {
+	machines := []*Machine{}
 	for _, machine := range ml.Machines {
-		bmh := &machine.BMH
-		fmt.Printf("RemoveLabels bmh.ObjectMeta.Name:%s\n", bmh.ObjectMeta.Name)
-		delete(bmh.Labels, SipClusterNamespaceLabel)
-		delete(bmh.Labels, SipClusterNameLabel)
-		delete(bmh.Labels, SipNodeTypeLabel)
+		if machine.ScheduleStatus == Scheduled || machine.ScheduleStatus == ToBeScheduled {
+			machines = append(machines, machine)
+		}
+	}
+	sort.Slice(machines, func(i, j int) bool {
+		if machines[i].BMHRole != machines[j].BMHRole {
+			return machines[i].BMHRole < machines[j].BMHRole
+		}
+		return machines[i].BMH.Name < machines[j].BMH.Name
+	})
+
+	nodes := []airshipv1.NodeStatus{}
+	active := map[airshipv1.BMHRole]int{}
+	for _, machine := range machines {
+		nodeSet := sip.Spec.Nodes[machine.BMHRole]
+		state := airshipv1.NodeStateStandby
+		if nodeSet.Count == nil || active[machine.BMHRole] < nodeSet.Count.Active {
+			state = airshipv1.NodeStateActive
+			active[machine.BMHRole]++
+		}
 
+		node := airshipv1.NodeStatus{
+			Name:      machine.BMH.Name,
+			Namespace: machine.BMH.Namespace,
+			Role:      machine.BMHRole,
+			State:     state,
+		}
+		if nodeSet.TopologyKey != "" {
+			node.TopologyDomain = machine.BMH.Labels[nodeSet.TopologyKey]
+		}
+		if machine.Data != nil && len(machine.Data.IPOnInterface) > 0 {
+			node.IPs = make(map[string]string, len(machine.Data.IPOnInterface))
+			for iface, ip := range machine.Data.IPOnInterface {
+				node.IPs[iface] = ip
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// RemoveLabels removes sip related labels
+func (ml *MachineList) RemoveLabels(sip airshipv1.SIPCluster, c client.Client) error {
+	ml.Log.Info("removing labels from BMHs", "count", len(ml.Machines))
+	for _, machine := range ml.Machines {
 		// This is bombing when it find 1 error
 		// Might be better to acculumalte the errors, and
 		// Allow it  to continue.
-		err := c.Update(context.Background(), bmh)
-		if err != nil {
-			fmt.Printf("RemoveLabels bmh:%s err:%v\n", bmh.ObjectMeta.Name, err)
+		if err := ml.removeLabels(sip, &machine.BMH, c); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (ml *MachineList) GetCluster(sip airshipv1.SIPCluster, c client.Client) error {
+func (ml *MachineList) removeLabels(sip airshipv1.SIPCluster, bmh *metal3.BareMetalHost, c client.Client) error {
+	ml.Log.Info("removing labels from BMH", "BMH", bmh.ObjectMeta.Name)
+	delete(bmh.Labels, SipClusterNamespaceLabel)
+	delete(bmh.Labels, SipClusterNameLabel)
+	delete(bmh.Labels, SipNodeTypeLabel)
+
+	err := c.Update(context.Background(), bmh)
+	if err != nil {
+		ml.Log.Error(err, "unable to remove labels from BMH", "BMH", bmh.ObjectMeta.Name)
+		return err
+	}
+	ml.recordEvent(sip, corev1.EventTypeNormal, ReasonHostReleased, "Released BMH %s/%s", bmh.Namespace, bmh.Name)
+	return nil
+}
+
+// DeprovisionTimeout is how long the BMHs of a SIPCluster with the Deprovision reclaim policy are waited on after the
+// SIPCluster is deleted. BMHs that are still not deprovisioned by then are released as they are, so that the deletion
+// of the SIPCluster completes.
+var DeprovisionTimeout = 30 * time.Minute
+
+// provisionedStates are the provisioning states of BMHs that hold an image, or that are having it written or removed.
+var provisionedStates = map[metal3.ProvisioningState]bool{
+	metal3.StateProvisioning:   true,
+	metal3.StateProvisioned:    true,
+	metal3.StateDeprovisioning: true,
+}
+
+// Deprovision starts the deprovisioning of the BMHs in the MachineList by removing their image, and removes the sip
+// related labels from the BMHs that do not hold an image anymore. BMHs that are being deleted or have failed are
+// released right away, since they are not going to be deprovisioned, and so are the BMHs that are not deprovisioned
+// within the DeprovisionTimeout. It returns the names of the BMHs that are still being deprovisioned.
+func (ml *MachineList) Deprovision(sip airshipv1.SIPCluster, c client.Client) ([]string, error) {
+	timedOut := !sip.DeletionTimestamp.IsZero() && time.Since(sip.DeletionTimestamp.Time) > DeprovisionTimeout
+	deprovisioning := []string{}
+	for _, machine := range ml.Machines {
+		bmh := &machine.BMH
+		switch {
+		case !bmh.DeletionTimestamp.IsZero() || bmh.HasError() ||
+			(bmh.Spec.Image == nil && !provisionedStates[bmh.Status.Provisioning.State]):
+			if err := ml.release(sip, bmh, c); err != nil {
+				return nil, err
+			}
+			continue
+		case timedOut:
+			ml.recordEvent(sip, corev1.EventTypeWarning, ReasonHostDeprovisionTimedOut,
+				"BMH %s/%s was not deprovisioned within %s", bmh.Namespace, bmh.Name, DeprovisionTimeout)
+			if err := ml.release(sip, bmh, c); err != nil {
+				return nil, err
+			}
+			continue
+		case bmh.Spec.Image != nil:
+			ml.Log.Info("deprovisioning BMH", "BMH", bmh.Name)
+			bmh.Spec.Image = nil
+			if err := c.Update(context.Background(), bmh); err != nil {
+				return nil, err
+			}
+			ml.recordEvent(sip, corev1.EventTypeNormal, ReasonHostDeprovisioning, "Deprovisioning BMH %s/%s",
+				bmh.Namespace, bmh.Name)
+		}
+		deprovisioning = append(deprovisioning, bmh.Name)
+	}
+
+	sort.Strings(deprovisioning)
+	return deprovisioning, nil
+}
+
+// release removes the image and the sip related labels of a BMH.
+func (ml *MachineList) release(sip airshipv1.SIPCluster, bmh *metal3.BareMetalHost, c client.Client) error {
+	bmh.Spec.Image = nil
+	return ml.removeLabels(sip, bmh, c)
+}
+
+// GetCluster collects the BMHs from the inventory that are labeled as scheduled to the SIPCluster.
+func (ml *MachineList) GetCluster(sip airshipv1.SIPCluster, inv Inventory) error {
 	// Initialize the Target list
 	ml.init(sip.Spec.Nodes)
 
-	bmhList := &metal3.BareMetalHostList{}
-	scheduleLabels := GetClusterLabels(sip)
-	err := c.List(context.Background(), bmhList, client.MatchingLabels(scheduleLabels))
+	bmhList, err := inv.ListHosts(labels.SelectorFromSet(GetClusterLabels(sip)))
 	if err != nil {
 		return err
 	}
 
-	for _, bmh := range bmhList.Items {
+	for _, bmh := range bmhList {
 		ml.Machines[bmh.ObjectMeta.Name] = &Machine{
 			BMH:            bmh,
 			ScheduleStatus: Scheduled,
@@ -752,7 +965,7 @@ func (ml *MachineList) GetCluster(sip airshipv1.SIPCluster, c client.Client) err
 		}
 	}
 
-	fmt.Printf("GetCluster %s \n", ml.String())
+	fmt.Fprintf(DebugOutput, "GetCluster %s \n", ml.String())
 	return nil
 }
 
@@ -1,16 +1,25 @@
 package bmh
 
 import (
+	"fmt"
+	"time"
+
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	mockClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/metrics"
 	"sipcluster/testutil"
 )
 
@@ -43,6 +52,12 @@ var _ = Describe("MachineList", func() {
 
 		err := metal3.AddToScheme(scheme.Scheme)
 		Expect(err).NotTo(HaveOccurred())
+
+		// Metal3 IPAM objects are handled as unstructured objects
+		scheme.Scheme.AddKnownTypeWithName(ipClaimListGVK.GroupVersion().WithKind("IPClaim"),
+			&unstructured.Unstructured{})
+		scheme.Scheme.AddKnownTypeWithName(ipClaimListGVK, &unstructured.UnstructuredList{})
+		scheme.Scheme.AddKnownTypeWithName(ipAddressGVK, &unstructured.Unstructured{})
 	})
 
 	It("Should report if it has a machine registered for a BMH object", func() {
@@ -72,11 +87,11 @@ var _ = Describe("MachineList", func() {
 		}
 
 		k8sClient := mockClient.NewFakeClient(objs...)
-		bmhList, err := machineList.getBMHs(k8sClient)
+		bmhList, err := machineList.getBMHs(NewKubernetesInventory(k8sClient))
 		Expect(err).To(BeNil())
 
 		// Validate that the BMH list does not contain scheduled nodes
-		for _, bmh := range bmhList.Items {
+		for _, bmh := range bmhList {
 			for _, scheduled := range scheduledNodes {
 				Expect(bmh).ToNot(Equal(scheduled))
 				Expect(testutil.CompareLabels(unscheduledSelector, bmh.Labels)).To(Succeed())
@@ -94,8 +109,8 @@ var _ = Describe("MachineList", func() {
 		}
 
 		k8sClient := mockClient.NewFakeClient(objs...)
-		_, err := machineList.getBMHs(k8sClient)
-		Expect(err).ToNot(BeNil())
+		_, err := machineList.getBMHs(NewKubernetesInventory(k8sClient))
+		Expect(err).To(BeAssignableToTypeOf(ErrNoHostsAvailable{}))
 	})
 
 	It("Should retrieve the BMH IP from the BMH's NetworkData secret when infra services are defined", func() {
@@ -161,7 +176,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, k8sClient)).To(BeNil())
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).To(BeNil())
 
 		Expect(ml.Machines[bmh.Name].Data.IPOnInterface).To(Equal(map[string]string{"oam-ipv4": "32.68.51.139"}))
 	})
@@ -230,7 +245,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, k8sClient)).To(BeNil())
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).To(BeNil())
 
 		Expect(ml.Machines[bmh.Name].Data.IPOnInterface).To(Equal(map[string]string{"oam-ipv4": "32.68.51.139"}))
 	})
@@ -298,7 +313,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateBMCAuth(*sipCluster, k8sClient)).To(BeNil())
+		Expect(ml.ExtrapolateBMCAuth(*sipCluster, NewKubernetesInventory(k8sClient))).To(BeNil())
 
 		Expect(ml.Machines[bmh.Name].Data.BMCUsername).To(Equal(username))
 		Expect(ml.Machines[bmh.Name].Data.BMCPassword).To(Equal(password))
@@ -329,7 +344,8 @@ var _ = Describe("MachineList", func() {
 				airshipv1.RoleControlPlane: 1,
 				airshipv1.RoleWorker:       0,
 			},
-			Log: ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+			Log:      ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+			Recorder: record.NewFakeRecorder(1),
 		}
 
 		sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", "default", 1, 3)
@@ -364,7 +380,9 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateBMCAuth(*sipCluster, k8sClient)).ToNot(BeNil())
+		Expect(ml.ExtrapolateBMCAuth(*sipCluster, NewKubernetesInventory(k8sClient))).ToNot(BeNil())
+		Expect(<-ml.Recorder.(*record.FakeRecorder).Events).To(HavePrefix(
+			"Warning HostRejected Rejected BMH default/node01 for the ControlPlane node set: "))
 	})
 
 	It("Should not process a BMH when its BMC secret is incorrectly formatted", func() {
@@ -434,7 +452,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateBMCAuth(*sipCluster, k8sClient)).ToNot(BeNil())
+		Expect(ml.ExtrapolateBMCAuth(*sipCluster, NewKubernetesInventory(k8sClient))).ToNot(BeNil())
 	})
 
 	It("Should not process a BMH when its Network Data secret is missing", func() {
@@ -498,7 +516,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, k8sClient)).ToNot(BeNil())
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).ToNot(BeNil())
 	})
 
 	It("Should not process a BMH when its Network Data secret is incorrectly formatted", func() {
@@ -561,7 +579,7 @@ var _ = Describe("MachineList", func() {
 		}
 		objsToApply = append(objsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objsToApply...)
-		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, k8sClient)).ToNot(BeNil())
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).ToNot(BeNil())
 	})
 
 	It("Should not retrieve the BMH IP if it has been previously extrapolated", func() {
@@ -577,7 +595,95 @@ var _ = Describe("MachineList", func() {
 		sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", "default", 1, 3)
 		objectsToApply = append(objectsToApply, nodeSSHPrivateKeys)
 		k8sClient := mockClient.NewFakeClient(objectsToApply...)
-		Expect(machineList.ExtrapolateServiceAddresses(*sipCluster, k8sClient)).To(BeNil())
+		Expect(machineList.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).To(BeNil())
+	})
+
+	It("Should retrieve the BMH IP from Metal3 IPAM when the BMH uses IPAM addresses", func() {
+		bmh, _ := testutil.CreateBMH(1, "default", airshipv1.RoleControlPlane, 6)
+		bmh.Spec.NetworkData = nil
+		bmh.Annotations = map[string]string{SipAddressSourceAnnotation: AddressSourceIPAM}
+
+		m, err := NewMachine(*bmh, airshipv1.RoleControlPlane, NotScheduled)
+		Expect(err).To(BeNil())
+
+		ml := &MachineList{
+			NamespacedName: types.NamespacedName{
+				Name:      "bmh",
+				Namespace: "default",
+			},
+			Machines: map[string]*Machine{
+				bmh.Name: m,
+			},
+			ReadyForScheduleCount: map[airshipv1.BMHRole]int{
+				airshipv1.RoleControlPlane: 1,
+			},
+			Log: ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+		}
+
+		ipClaim, ipAddress := testutil.CreateIPAMAddress(bmh.Name, "default", "oam-ipv4", "10.23.25.101")
+		sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", "default", 1, 3)
+		k8sClient := mockClient.NewFakeClient(bmh, ipClaim, ipAddress, nodeSSHPrivateKeys)
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).To(BeNil())
+
+		Expect(ml.Machines[bmh.Name].Data.IPOnInterface).To(Equal(map[string]string{"oam-ipv4": "10.23.25.101"}))
+	})
+
+	It("Should not process a BMH when its IPAM claim has no address", func() {
+		bmh, _ := testutil.CreateBMH(1, "default", airshipv1.RoleControlPlane, 6)
+		bmh.Spec.NetworkData = nil
+		bmh.Annotations = map[string]string{SipAddressSourceAnnotation: AddressSourceIPAM}
+
+		m, err := NewMachine(*bmh, airshipv1.RoleControlPlane, NotScheduled)
+		Expect(err).To(BeNil())
+
+		ml := &MachineList{
+			NamespacedName: types.NamespacedName{
+				Name:      "bmh",
+				Namespace: "default",
+			},
+			Machines: map[string]*Machine{
+				bmh.Name: m,
+			},
+			ReadyForScheduleCount: map[airshipv1.BMHRole]int{
+				airshipv1.RoleControlPlane: 1,
+			},
+			Log: ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+		}
+
+		ipClaim, _ := testutil.CreateIPAMAddress(bmh.Name, "default", "oam-ipv4", "10.23.25.101")
+		unstructured.RemoveNestedField(ipClaim.Object, "status")
+		sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", "default", 1, 3)
+		k8sClient := mockClient.NewFakeClient(bmh, ipClaim, nodeSSHPrivateKeys)
+		Expect(ml.ExtrapolateServiceAddresses(*sipCluster, NewKubernetesInventory(k8sClient))).ToNot(BeNil())
+		Expect(ml.Machines[bmh.Name].ScheduleStatus).To(Equal(UnableToSchedule))
+	})
+
+	It("Should count a rejected BMH as excluded only once", func() {
+		bmh, _ := testutil.CreateBMH(1, "default", airshipv1.RoleControlPlane, 6)
+		m, err := NewMachine(*bmh, airshipv1.RoleControlPlane, ToBeScheduled)
+		Expect(err).To(BeNil())
+
+		ml := &MachineList{
+			Machines: map[string]*Machine{
+				bmh.Name: m,
+			},
+			ReadyForScheduleCount: map[airshipv1.BMHRole]int{
+				airshipv1.RoleControlPlane: 1,
+			},
+			Log: ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+		}
+
+		// The BMC credentials Secret of the BMH does not exist
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 0)
+		inventory := NewKubernetesInventory(mockClient.NewFakeClient(bmh))
+		excluded := metrics.HostsExcluded.WithLabelValues(metrics.ExclusionBMCCredentials)
+		before := promtestutil.ToFloat64(excluded)
+
+		Expect(ml.ExtrapolateBMCAuth(*sipCluster, inventory)).ToNot(BeNil())
+		Expect(ml.ExtrapolateBMCAuth(*sipCluster, inventory)).To(BeNil())
+		Expect(ml.Machines[bmh.Name].ScheduleStatus).To(Equal(UnableToSchedule))
+		Expect(ml.ReadyForScheduleCount[airshipv1.RoleControlPlane]).To(Equal(0))
+		Expect(promtestutil.ToFloat64(excluded) - before).To(Equal(float64(1)))
 	})
 
 	It("Should not schedule BMH if it is missing networkdata", func() {
@@ -587,4 +693,229 @@ var _ = Describe("MachineList", func() {
 		_, err := NewMachine(*bmh, airshipv1.RoleControlPlane, NotScheduled)
 		Expect(err).ToNot(BeNil())
 	})
+
+	It("Should not schedule BMHs that are unavailable", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 0)
+		hosts := []runtime.Object{}
+		for node, state := range []metal3.ProvisioningState{metal3.StateProvisioned, metal3.StateDeprovisioning,
+			metal3.StateReady} {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleControlPlane, 6)
+			bmh.Status.Provisioning.State = state
+			hosts = append(hosts, bmh)
+		}
+		machineList = &MachineList{Log: ctrl.Log.WithName("controllers").WithName("SIPCluster")}
+
+		inventory := NewKubernetesInventory(mockClient.NewFakeClient(hosts...))
+		Expect(machineList.Schedule(*sipCluster, inventory)).To(Succeed())
+		Expect(machineList.Machines).To(HaveLen(1))
+		Expect(machineList.Machines).To(HaveKey("node02"))
+	})
+
+	It("Should not schedule BMHs for a node set without a count", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 0)
+		nodeSet := sipCluster.Spec.Nodes[airshipv1.RoleWorker]
+		nodeSet.Count = nil
+		sipCluster.Spec.Nodes[airshipv1.RoleWorker] = nodeSet
+		bmh, _ := testutil.CreateBMH(0, "default", airshipv1.RoleControlPlane, 6)
+		machineList = &MachineList{Log: ctrl.Log.WithName("controllers").WithName("SIPCluster")}
+
+		inventory := NewKubernetesInventory(mockClient.NewFakeClient(bmh))
+		Expect(machineList.Schedule(*sipCluster, inventory)).To(Succeed())
+		Expect(machineList.Machines).To(HaveLen(1))
+		Expect(machineList.Machines[bmh.Name].BMHRole).To(Equal(airshipv1.RoleControlPlane))
+	})
+
+	It("Should report the scheduled machines as active and standby nodes", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		machines := map[string]*Machine{}
+		for node, state := range []ScheduledState{Scheduled, ToBeScheduled, NotScheduled} {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleControlPlane, 6)
+			machines[bmh.Name] = &Machine{
+				BMH:            *bmh,
+				BMHRole:        airshipv1.RoleControlPlane,
+				ScheduleStatus: state,
+				Data: &MachineData{
+					IPOnInterface: map[string]string{"oam-ipv4": fmt.Sprintf("32.68.51.%d", node)},
+				},
+			}
+		}
+		machineList.Machines = machines
+
+		Expect(machineList.NodeStatus(*sipCluster)).To(Equal([]airshipv1.NodeStatus{
+			{
+				Name:           "node00",
+				Namespace:      "default",
+				Role:           airshipv1.RoleControlPlane,
+				State:          airshipv1.NodeStateActive,
+				TopologyDomain: "stl2r6o0",
+				IPs:            map[string]string{"oam-ipv4": "32.68.51.0"},
+			},
+			{
+				Name:           "node01",
+				Namespace:      "default",
+				Role:           airshipv1.RoleControlPlane,
+				State:          airshipv1.NodeStateStandby,
+				TopologyDomain: "stl2r6o1",
+				IPs:            map[string]string{"oam-ipv4": "32.68.51.1"},
+			},
+		}))
+	})
+
+	Context("When restoring the BMHs recorded in the status of a SIPCluster", func() {
+		var sipCluster *airshipv1.SIPCluster
+		var hosts []runtime.Object
+		var nodes []airshipv1.NodeStatus
+
+		BeforeEach(func() {
+			sipCluster, _ = testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+			hosts = []runtime.Object{}
+			nodes = []airshipv1.NodeStatus{}
+			for node, role := range []airshipv1.BMHRole{airshipv1.RoleControlPlane, airshipv1.RoleWorker} {
+				bmh, _ := testutil.CreateBMH(node, "default", role, 6)
+				if role == airshipv1.RoleControlPlane {
+					for k, v := range GetClusterLabels(*sipCluster) {
+						bmh.Labels[k] = v
+					}
+					bmh.Labels[SipNodeTypeLabel] = string(role)
+				}
+				hosts = append(hosts, bmh)
+				nodes = append(nodes, airshipv1.NodeStatus{Name: bmh.Name, Namespace: bmh.Namespace, Role: role})
+			}
+			machineList = &MachineList{Log: ctrl.Log.WithName("controllers").WithName("SIPCluster")}
+		})
+
+		It("Should select the recorded BMHs again", func() {
+			inventory := NewKubernetesInventory(mockClient.NewFakeClient(hosts...))
+			Expect(machineList.Restore(*sipCluster, nodes, inventory)).To(Succeed())
+
+			Expect(machineList.Machines).To(HaveLen(2))
+			Expect(machineList.Machines["node00"].ScheduleStatus).To(Equal(Scheduled))
+			Expect(machineList.Machines["node01"].ScheduleStatus).To(Equal(ToBeScheduled))
+			Expect(machineList.Machines["node01"].BMHRole).To(BeEquivalentTo(airshipv1.RoleWorker))
+		})
+
+		It("Should not select a recorded BMH that was scheduled to another SIPCluster", func() {
+			bmh := hosts[1].(*metal3.BareMetalHost)
+			bmh.Labels[SipClusterNamespaceLabel] = "default"
+			bmh.Labels[SipClusterNameLabel] = "subcluster-2"
+
+			inventory := NewKubernetesInventory(mockClient.NewFakeClient(hosts...))
+			err := machineList.Restore(*sipCluster, nodes, inventory)
+			Expect(err).To(MatchError(ErrHostNotRestorable{
+				Host:   "node01",
+				Reason: "the BMH is scheduled to another SIPCluster",
+			}))
+		})
+
+		It("Should not select recorded BMHs that do not fill the node sets", func() {
+			inventory := NewKubernetesInventory(mockClient.NewFakeClient(hosts...))
+			err := machineList.Restore(*sipCluster, nodes[:1], inventory)
+			Expect(err).To(BeAssignableToTypeOf(ErrorUnableToFullySchedule{}))
+		})
+	})
+
+	It("Should only release deprovisioned BMHs", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		states := []metal3.ProvisioningState{metal3.StateProvisioned, metal3.StateDeprovisioning, metal3.StateReady}
+		objs := []runtime.Object{}
+		machines := map[string]*Machine{}
+		for node, state := range states {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleControlPlane, 6)
+			for k, v := range GetClusterLabels(*sipCluster) {
+				bmh.Labels[k] = v
+			}
+			bmh.Status.Provisioning.State = state
+			if state == metal3.StateProvisioned {
+				bmh.Spec.Image = &metal3.Image{URL: "http://example.org/image.qcow2"}
+			}
+			objs = append(objs, bmh)
+			machines[bmh.Name] = &Machine{BMH: *bmh, BMHRole: airshipv1.RoleControlPlane, ScheduleStatus: Scheduled}
+		}
+		k8sClient := mockClient.NewFakeClient(objs...)
+		recorder := record.NewFakeRecorder(len(states))
+		machineList.Machines = machines
+		machineList.Recorder = recorder
+
+		deprovisioning, err := machineList.Deprovision(*sipCluster, k8sClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deprovisioning).To(Equal([]string{"node00", "node01"}))
+		Expect([]string{<-recorder.Events, <-recorder.Events}).To(ConsistOf(
+			"Normal HostDeprovisioning Deprovisioning BMH default/node00",
+			"Normal HostReleased Released BMH default/node02",
+		))
+		Expect(recorder.Events).To(BeEmpty())
+
+		hosts, err := NewKubernetesInventory(k8sClient).ListHosts(
+			labels.SelectorFromSet(GetClusterLabels(*sipCluster)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hosts).To(HaveLen(2))
+		for _, bmh := range hosts {
+			Expect(bmh.Spec.Image).To(BeNil())
+		}
+	})
+
+	It("Should release BMHs that are not going to be deprovisioned", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		now := metav1.Now()
+		objs := []runtime.Object{}
+		machines := map[string]*Machine{}
+		for node, mutate := range []func(*metal3.BareMetalHost){
+			func(bmh *metal3.BareMetalHost) { bmh.Status.Provisioning.State = metal3.StateExternallyProvisioned },
+			func(bmh *metal3.BareMetalHost) { bmh.Status.Provisioning.State = metal3.StateInspecting },
+			func(bmh *metal3.BareMetalHost) {
+				bmh.Spec.Image = &metal3.Image{URL: "http://example.org/image.qcow2"}
+				bmh.Status.Provisioning.State = metal3.StateProvisioningError
+				bmh.Status.ErrorType = metal3.ProvisioningError
+				bmh.Status.ErrorMessage = "failed to write image"
+			},
+			func(bmh *metal3.BareMetalHost) {
+				bmh.Spec.Image = &metal3.Image{URL: "http://example.org/image.qcow2"}
+				bmh.Status.Provisioning.State = metal3.StateProvisioned
+				bmh.DeletionTimestamp = &now
+			},
+		} {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleControlPlane, 6)
+			for k, v := range GetClusterLabels(*sipCluster) {
+				bmh.Labels[k] = v
+			}
+			mutate(bmh)
+			objs = append(objs, bmh)
+			machines[bmh.Name] = &Machine{BMH: *bmh, BMHRole: airshipv1.RoleControlPlane, ScheduleStatus: Scheduled}
+		}
+		k8sClient := mockClient.NewFakeClient(objs...)
+		machineList.Machines = machines
+
+		deprovisioning, err := machineList.Deprovision(*sipCluster, k8sClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deprovisioning).To(BeEmpty())
+
+		hosts, err := NewKubernetesInventory(k8sClient).ListHosts(
+			labels.SelectorFromSet(GetClusterLabels(*sipCluster)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hosts).To(BeEmpty())
+	})
+
+	It("Should release BMHs that are not deprovisioned within the timeout", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		deleted := metav1.NewTime(time.Now().Add(-DeprovisionTimeout - time.Minute))
+		sipCluster.DeletionTimestamp = &deleted
+		bmh, _ := testutil.CreateBMH(0, "default", airshipv1.RoleControlPlane, 6)
+		for k, v := range GetClusterLabels(*sipCluster) {
+			bmh.Labels[k] = v
+		}
+		bmh.Status.Provisioning.State = metal3.StateDeprovisioning
+		k8sClient := mockClient.NewFakeClient(bmh)
+		recorder := record.NewFakeRecorder(2)
+		machineList.Machines = map[string]*Machine{
+			bmh.Name: {BMH: *bmh, BMHRole: airshipv1.RoleControlPlane, ScheduleStatus: Scheduled},
+		}
+		machineList.Recorder = recorder
+
+		deprovisioning, err := machineList.Deprovision(*sipCluster, k8sClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deprovisioning).To(BeEmpty())
+		Expect(<-recorder.Events).To(Equal("Warning HostDeprovisionTimedOut BMH default/node00 was not deprovisioned " +
+			"within 30m0s"))
+		Expect(<-recorder.Events).To(Equal("Normal HostReleased Released BMH default/node00"))
+	})
 })
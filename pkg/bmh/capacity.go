@@ -0,0 +1,133 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bmh
+
+import (
+	"sort"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+// Capacity counts the free and claimed hosts of each group of a SIPInventory. Hosts are matched against the groups
+// and broken down by topology domain in the same way the scheduler does, so that the free hosts of a group are the
+// hosts a SIPCluster node set with the same labelSelector and topologyKey could be scheduled to. Unclaimed hosts that
+// the scheduler does not accept, such as hosts that are provisioned, failed or being deleted, are not counted.
+func Capacity(spec airshipv1.SIPInventorySpec, inv Inventory) ([]airshipv1.InventoryGroupStatus, error) {
+	hosts, err := inv.ListHosts(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]airshipv1.InventoryGroupStatus, 0, len(spec.Groups))
+	for _, group := range spec.Groups {
+		status, err := groupCapacity(group, spec.TopologyKeys, hosts)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, status)
+	}
+
+	return groups, nil
+}
+
+// groupCapacity counts the hosts of a group, in total and by topology domain.
+func groupCapacity(group airshipv1.InventoryGroup, topologyKeys []string,
+	hosts []metal3.BareMetalHost) (airshipv1.InventoryGroupStatus, error) {
+	status := airshipv1.InventoryGroupStatus{Name: group.Name}
+	domains := map[airshipv1.TopologyDomainStatus]*airshipv1.HostCounts{}
+
+	for _, host := range hosts {
+		hostLabels := labels.Set(host.Labels)
+		// Whether a host matches the selector does not depend on the topology key of the ScheduleSet
+		_, match, err := (&ScheduleSet{}).GetLabels(hostLabels, &group.LabelSelector)
+		if err != nil {
+			return status, err
+		}
+		if !match {
+			continue
+		}
+
+		_, claimed := hostLabels[SipClusterNameLabel]
+		// Unclaimed hosts that the scheduler would skip are neither free nor claimed
+		if !claimed && !schedulable(host) {
+			continue
+		}
+		counts := []*airshipv1.HostCounts{&status.HostCounts}
+		for _, key := range topologyKeys {
+			scheduleSet := &ScheduleSet{topologyKey: key}
+			value, _, _ := scheduleSet.GetLabels(hostLabels, &group.LabelSelector)
+
+			domain := airshipv1.TopologyDomainStatus{Key: key, Value: value}
+			if domains[domain] == nil {
+				domains[domain] = &airshipv1.HostCounts{}
+			}
+			counts = append(counts, domains[domain])
+		}
+
+		for _, c := range counts {
+			if claimed {
+				c.Claimed++
+			} else {
+				c.Free++
+			}
+		}
+		if claimed {
+			addClaimedHost(&status, host)
+		}
+	}
+
+	for domain, counts := range domains {
+		domain.HostCounts = *counts
+		status.TopologyDomains = append(status.TopologyDomains, domain)
+	}
+	sort.Slice(status.TopologyDomains, func(i, j int) bool {
+		di, dj := status.TopologyDomains[i], status.TopologyDomains[j]
+		if di.Key != dj.Key {
+			return di.Key < dj.Key
+		}
+		return di.Value < dj.Value
+	})
+	sort.Slice(status.ClaimedHosts, func(i, j int) bool {
+		hi, hj := status.ClaimedHosts[i], status.ClaimedHosts[j]
+		if hi.Namespace != hj.Namespace {
+			return hi.Namespace < hj.Namespace
+		}
+		return hi.Name < hj.Name
+	})
+
+	return status, nil
+}
+
+// addClaimedHost records a claimed host of a group and counts it for the role it was scheduled for.
+func addClaimedHost(status *airshipv1.InventoryGroupStatus, host metal3.BareMetalHost) {
+	role := airshipv1.BMHRole(host.Labels[SipNodeTypeLabel])
+	if role != "" {
+		if status.Roles == nil {
+			status.Roles = map[airshipv1.BMHRole]int{}
+		}
+		status.Roles[role]++
+	}
+	status.ClaimedHosts = append(status.ClaimedHosts, airshipv1.ClaimedHost{
+		Name:       host.Name,
+		Namespace:  host.Namespace,
+		Role:       role,
+		SIPCluster: host.Labels[SipClusterNamespaceLabel] + "/" + host.Labels[SipClusterNameLabel],
+	})
+}
@@ -0,0 +1,154 @@
+package bmh
+
+import (
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	mockClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("Capacity", func() {
+	var inventory Inventory
+
+	BeforeEach(func() {
+		Expect(metal3.AddToScheme(scheme.Scheme)).To(Succeed())
+
+		// Control plane hosts in racks r6, r6 and r7; worker hosts in rack r8
+		objs := []runtime.Object{}
+		for node, rack := range []int{6, 6, 7} {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleControlPlane, rack)
+			if node == 0 {
+				bmh.Labels[SipClusterNamespaceLabel] = "tenant"
+				bmh.Labels[SipClusterNameLabel] = "subcluster-1"
+				bmh.Labels[SipNodeTypeLabel] = string(airshipv1.RoleControlPlane)
+			}
+			objs = append(objs, bmh)
+		}
+		for node := 3; node < 5; node++ {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleWorker, 8)
+			if node == 3 {
+				bmh.Labels[SipClusterNamespaceLabel] = "tenant"
+				bmh.Labels[SipClusterNameLabel] = "subcluster-1"
+				bmh.Labels[SipNodeTypeLabel] = string(airshipv1.RoleWorker)
+			}
+			objs = append(objs, bmh)
+		}
+
+		inventory = NewKubernetesInventory(mockClient.NewFakeClient(objs...))
+	})
+
+	It("Should count free and claimed hosts by group and topology domain", func() {
+		spec := airshipv1.SIPInventorySpec{
+			Groups: []airshipv1.InventoryGroup{
+				{
+					Name: "control-plane",
+					LabelSelector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"example.org/bmh-label": "control-plane"},
+					},
+				},
+				{
+					Name: "worker",
+					LabelSelector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"example.org/bmh-label": "worker"},
+					},
+				},
+			},
+			TopologyKeys: []string{testutil.RackLabel},
+		}
+
+		groups, err := Capacity(spec, inventory)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(Equal([]airshipv1.InventoryGroupStatus{
+			{
+				Name:       "control-plane",
+				HostCounts: airshipv1.HostCounts{Free: 2, Claimed: 1},
+				Roles:      map[airshipv1.BMHRole]int{airshipv1.RoleControlPlane: 1},
+				TopologyDomains: []airshipv1.TopologyDomainStatus{
+					{Key: testutil.RackLabel, Value: "r6", HostCounts: airshipv1.HostCounts{Free: 1, Claimed: 1}},
+					{Key: testutil.RackLabel, Value: "r7", HostCounts: airshipv1.HostCounts{Free: 1}},
+				},
+				ClaimedHosts: []airshipv1.ClaimedHost{
+					{Name: "node00", Namespace: "default", Role: airshipv1.RoleControlPlane,
+						SIPCluster: "tenant/subcluster-1"},
+				},
+			},
+			{
+				Name:       "worker",
+				HostCounts: airshipv1.HostCounts{Free: 1, Claimed: 1},
+				Roles:      map[airshipv1.BMHRole]int{airshipv1.RoleWorker: 1},
+				TopologyDomains: []airshipv1.TopologyDomainStatus{
+					{Key: testutil.RackLabel, Value: "r8", HostCounts: airshipv1.HostCounts{Free: 1, Claimed: 1}},
+				},
+				ClaimedHosts: []airshipv1.ClaimedHost{
+					{Name: "node03", Namespace: "default", Role: airshipv1.RoleWorker,
+						SIPCluster: "tenant/subcluster-1"},
+				},
+			},
+		}))
+	})
+
+	It("Should count every host in a group with an empty selector", func() {
+		spec := airshipv1.SIPInventorySpec{
+			Groups: []airshipv1.InventoryGroup{{Name: "all"}},
+		}
+
+		groups, err := Capacity(spec, inventory)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].HostCounts).To(Equal(airshipv1.HostCounts{Free: 3, Claimed: 2}))
+		Expect(groups[0].TopologyDomains).To(BeEmpty())
+		Expect(groups[0].ClaimedHosts).To(HaveLen(2))
+	})
+
+	It("Should not count hosts that the scheduler does not accept as free", func() {
+		now := metav1.Now()
+		objs := []runtime.Object{}
+		for node, mutate := range []func(*metal3.BareMetalHost){
+			func(bmh *metal3.BareMetalHost) {},
+			func(bmh *metal3.BareMetalHost) { bmh.Status.Provisioning.State = metal3.StateProvisioning },
+			func(bmh *metal3.BareMetalHost) { bmh.Status.Provisioning.State = metal3.StateExternallyProvisioned },
+			func(bmh *metal3.BareMetalHost) {
+				bmh.Status.ErrorType = metal3.RegistrationError
+				bmh.Status.ErrorMessage = "failed to register"
+			},
+			func(bmh *metal3.BareMetalHost) { bmh.DeletionTimestamp = &now },
+			func(bmh *metal3.BareMetalHost) { bmh.Spec.NetworkData = nil },
+		} {
+			bmh, _ := testutil.CreateBMH(node, "default", airshipv1.RoleWorker, 8)
+			mutate(bmh)
+			objs = append(objs, bmh)
+		}
+		spec := airshipv1.SIPInventorySpec{
+			Groups: []airshipv1.InventoryGroup{{Name: "all"}},
+		}
+
+		groups, err := Capacity(spec, NewKubernetesInventory(mockClient.NewFakeClient(objs...)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].HostCounts).To(Equal(airshipv1.HostCounts{Free: 1}))
+	})
+
+	It("Should return an error for an invalid selector", func() {
+		spec := airshipv1.SIPInventorySpec{
+			Groups: []airshipv1.InventoryGroup{
+				{
+					Name: "invalid",
+					LabelSelector: metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: testutil.RackLabel, Operator: "Unknown"},
+						},
+					},
+				},
+			},
+		}
+
+		_, err := Capacity(spec, inventory)
+		Expect(err).To(HaveOccurred())
+	})
+})
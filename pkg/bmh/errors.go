@@ -2,9 +2,11 @@ package bmh
 
 import (
 	"fmt"
+	"strings"
 
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	airshipv1 "sipcluster/pkg/api/v1"
 )
@@ -27,6 +29,15 @@ func (e ErrorUnableToFullySchedule) Error() string {
 		e.TargetNode, e.TargetLabelSelector)
 }
 
+// ErrNoHostsAvailable occurs when every BMH in the inventory is already claimed by a SIPCluster.
+type ErrNoHostsAvailable struct {
+	Selector labels.Selector
+}
+
+func (e ErrNoHostsAvailable) Error() string {
+	return fmt.Sprintf("Unable to identify BMH available for scheduling. Selecting %v", e.Selector)
+}
+
 type ErrorHostIPNotFound struct {
 	HostName    string
 	IPInterface string
@@ -47,6 +58,17 @@ func (e ErrorNetworkDataNotFound) Error() string {
 	return fmt.Sprintf("vBMH Host %v does not define NetworkData, but is required for scheduling.", e.BMH)
 }
 
+// ErrHostUnavailable occurs when a host that is not scheduled yet is being deleted, has failed, or is provisioned or
+// being provisioned, and can not be scheduled to a SIPCluster.
+type ErrHostUnavailable struct {
+	BMH    string
+	Reason string
+}
+
+func (e ErrHostUnavailable) Error() string {
+	return fmt.Sprintf("BMH %s is unavailable for scheduling: %s", e.BMH, e.Reason)
+}
+
 // ErrMalformedManagementCredentials occurs when a BMC credentials secret does not contain username and password fields.
 type ErrMalformedManagementCredentials struct {
 	SecretName string
@@ -56,3 +78,32 @@ func (e ErrMalformedManagementCredentials) Error() string {
 	return fmt.Sprintf("secret %s contains malformed management credentials. Must contain '%s' and '%s' fields.",
 		e.SecretName, keyBMCUsername, keyBMCPassword)
 }
+
+// ErrSecretNotFound occurs when a Secret referenced by a BMH is not part of a file-based inventory.
+type ErrSecretNotFound struct {
+	Namespace string
+	Name      string
+}
+
+func (e ErrSecretNotFound) Error() string {
+	return fmt.Sprintf("secret %s/%s was not found in the inventory", e.Namespace, e.Name)
+}
+
+// ErrHostsDeprovisioning occurs when the BMHs of a SIPCluster that is being deleted have not been deprovisioned yet.
+type ErrHostsDeprovisioning struct {
+	Hosts []string
+}
+
+func (e ErrHostsDeprovisioning) Error() string {
+	return fmt.Sprintf("waiting for BMHs %s to be deprovisioned", strings.Join(e.Hosts, ", "))
+}
+
+// ErrHostNotRestorable occurs when a BMH that is recorded in the status of a SIPCluster can no longer be used for it.
+type ErrHostNotRestorable struct {
+	Host   string
+	Reason string
+}
+
+func (e ErrHostNotRestorable) Error() string {
+	return fmt.Sprintf("unable to reuse BMH %s: %s", e.Host, e.Reason)
+}
@@ -0,0 +1,70 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bmh
+
+import (
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/metrics"
+)
+
+// Reasons of the events that are recorded on a SIPCluster for its BMHs
+const (
+	// ReasonHostSelected is recorded when the scheduler selects a BMH for a SIPCluster.
+	ReasonHostSelected = "HostSelected"
+	// ReasonHostRejected is recorded when a BMH that the scheduler considered cannot be used for a SIPCluster.
+	ReasonHostRejected = "HostRejected"
+	// ReasonHostDeprovisioning is recorded when the image of a BMH is removed on deletion of its SIPCluster.
+	ReasonHostDeprovisioning = "HostDeprovisioning"
+	// ReasonHostDeprovisionTimedOut is recorded when a BMH is released without being deprovisioned, because it was
+	// not deprovisioned within the DeprovisionTimeout.
+	ReasonHostDeprovisionTimedOut = "HostDeprovisionTimedOut"
+	// ReasonHostReleased is recorded when the SIP labels are removed from a BMH on deletion of its SIPCluster.
+	ReasonHostReleased = "HostReleased"
+)
+
+// recordEvent records an event on the SIPCluster, if the MachineList has a Recorder.
+func (ml *MachineList) recordEvent(sip airshipv1.SIPCluster, eventType, reason, messageFmt string,
+	args ...interface{}) {
+	if ml.Recorder == nil {
+		return
+	}
+	ml.Recorder.Eventf(&sip, eventType, reason, messageFmt, args...)
+}
+
+// recordRejection records a warning event on the SIPCluster for a BMH that cannot be used for it, and counts the BMH
+// as excluded for the given reason.
+func (ml *MachineList) recordRejection(sip airshipv1.SIPCluster, host metal3.BareMetalHost, role airshipv1.BMHRole,
+	reason string, err error) {
+	ml.countExclusion(host, reason)
+	ml.recordEvent(sip, corev1.EventTypeWarning, ReasonHostRejected, "Rejected BMH %s/%s for the %s node set: %v",
+		host.Namespace, host.Name, role, err)
+}
+
+// countExclusion counts a BMH as excluded for the given reason, unless the MachineList has counted it already.
+func (ml *MachineList) countExclusion(host metal3.BareMetalHost, reason string) {
+	if ml.excluded[host.Name] {
+		return
+	}
+	if ml.excluded == nil {
+		ml.excluded = make(map[string]bool)
+	}
+	ml.excluded[host.Name] = true
+	metrics.HostsExcluded.WithLabelValues(reason).Inc()
+}
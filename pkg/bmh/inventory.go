@@ -0,0 +1,233 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bmh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+// Inventory provides the BMHs that SIP schedules from, along with the network data and BMC credentials of each BMH.
+type Inventory interface {
+	// ListHosts returns the BMHs whose labels match the selector.
+	ListHosts(selector labels.Selector) ([]metal3.BareMetalHost, error)
+	// GetNetworkData returns the networks of a BMH, and the IP address the BMH holds on each of them.
+	GetNetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error)
+	// GetBMCCredentials returns the username and password used to access the BMC of a BMH.
+	GetBMCCredentials(bmh metal3.BareMetalHost) (username string, password string, err error)
+}
+
+// KubernetesInventory is an Inventory backed by the BMHs and Secrets of a Kubernetes cluster.
+type KubernetesInventory struct {
+	client client.Client
+}
+
+// NewKubernetesInventory returns an Inventory that reads BMHs and Secrets through a controller-runtime client.
+func NewKubernetesInventory(c client.Client) *KubernetesInventory {
+	return &KubernetesInventory{client: c}
+}
+
+// ListHosts returns the BMHs in the cluster whose labels match the selector.
+func (inv *KubernetesInventory) ListHosts(selector labels.Selector) ([]metal3.BareMetalHost, error) {
+	bmhList := &metal3.BareMetalHostList{}
+	err := inv.client.List(context.Background(), bmhList, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return bmhList.Items, nil
+}
+
+// GetNetworkData returns the networks of a BMH from the address source selected for it.
+func (inv *KubernetesInventory) GetNetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error) {
+	return NewAddressSource(bmh, inv.client).NetworkData(bmh)
+}
+
+// GetBMCCredentials returns the BMC credentials stored in the Secret referenced by a BMH.
+func (inv *KubernetesInventory) GetBMCCredentials(bmh metal3.BareMetalHost) (string, string, error) {
+	bmcCredsSecret := &corev1.Secret{}
+	err := inv.client.Get(context.Background(), client.ObjectKey{
+		Namespace: bmh.Namespace,
+		Name:      bmh.Spec.BMC.CredentialsName,
+	}, bmcCredsSecret)
+	if err != nil {
+		return "", "", err
+	}
+	return getManagementCredentials(bmcCredsSecret)
+}
+
+// FileInventory is an Inventory backed by BMH and Secret manifests read from a directory. It lets the scheduler run
+// without a Kubernetes cluster, e.g. for capacity planning. Addresses are only read from Network Data Secrets; hosts
+// that use Metal3 IPAM are not supported.
+type FileInventory struct {
+	hosts   []metal3.BareMetalHost
+	secrets map[types.NamespacedName]corev1.Secret
+}
+
+// NewFileInventory reads every YAML or JSON file in dir, including its subdirectories, and keeps the BareMetalHosts
+// and Secrets they define. Other kinds of objects are ignored. Objects without a namespace are placed in the default
+// namespace.
+func NewFileInventory(dir string) (*FileInventory, error) {
+	inv := &FileInventory{
+		secrets: make(map[types.NamespacedName]corev1.Secret),
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		return inv.load(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (inv *FileInventory) load(data []byte) error {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		raw := map[string]interface{}{}
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		doc, err := yaml.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		if err = inv.add(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// add keeps the object defined by a document if it is a BareMetalHost or a Secret.
+func (inv *FileInventory) add(doc []byte) error {
+	typeMeta := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+		return err
+	}
+
+	switch {
+	case typeMeta.Kind == "BareMetalHost" && typeMeta.GroupVersionKind().Group == metal3.GroupVersion.Group:
+		bmh := metal3.BareMetalHost{}
+		if err := yaml.Unmarshal(doc, &bmh); err != nil {
+			return err
+		}
+		if bmh.Namespace == "" {
+			bmh.Namespace = metav1.NamespaceDefault
+		}
+		inv.hosts = append(inv.hosts, bmh)
+	case typeMeta.Kind == "Secret" && typeMeta.APIVersion == corev1.SchemeGroupVersion.String():
+		secret := corev1.Secret{}
+		if err := yaml.Unmarshal(doc, &secret); err != nil {
+			return err
+		}
+		if secret.Namespace == "" {
+			secret.Namespace = metav1.NamespaceDefault
+		}
+		// stringData is only merged into data by the API server, so do the same here.
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		for k, v := range secret.StringData {
+			secret.Data[k] = []byte(v)
+		}
+		inv.secrets[types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret
+	}
+	return nil
+}
+
+// ListHosts returns the BMHs read from the directory whose labels match the selector.
+func (inv *FileInventory) ListHosts(selector labels.Selector) ([]metal3.BareMetalHost, error) {
+	hosts := []metal3.BareMetalHost{}
+	for _, bmh := range inv.hosts {
+		if selector.Matches(labels.Set(bmh.Labels)) {
+			hosts = append(hosts, *bmh.DeepCopy())
+		}
+	}
+	return hosts, nil
+}
+
+// GetNetworkData returns the networks of a BMH from the Network Data Secret read from the directory.
+func (inv *FileInventory) GetNetworkData(bmh metal3.BareMetalHost) (*airshipv1.NetworkData, error) {
+	if bmh.Spec.NetworkData == nil {
+		return nil, &ErrorNetworkDataNotFound{BMH: bmh}
+	}
+
+	secret, err := inv.getSecret(bmh.Spec.NetworkData.Namespace, bmh.Spec.NetworkData.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseNetworkData(secret)
+}
+
+// GetBMCCredentials returns the BMC credentials from the Secret read from the directory.
+func (inv *FileInventory) GetBMCCredentials(bmh metal3.BareMetalHost) (string, string, error) {
+	secret, err := inv.getSecret(bmh.Namespace, bmh.Spec.BMC.CredentialsName)
+	if err != nil {
+		return "", "", err
+	}
+	return getManagementCredentials(secret)
+}
+
+func (inv *FileInventory) getSecret(namespace, name string) (*corev1.Secret, error) {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	secret, exists := inv.secrets[types.NamespacedName{Namespace: namespace, Name: name}]
+	if !exists {
+		return nil, ErrSecretNotFound{Namespace: namespace, Name: name}
+	}
+	return &secret, nil
+}
@@ -0,0 +1,159 @@
+package bmh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("FileInventory", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "sip-inventory")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	// writeInventory writes the objects as a single multi-document YAML file in the inventory directory.
+	writeInventory := func(name string, objs ...interface{}) {
+		var content []byte
+		for _, obj := range objs {
+			doc, err := yaml.Marshal(obj)
+			Expect(err).NotTo(HaveOccurred())
+			content = append(content, []byte("---\n")...)
+			content = append(content, doc...)
+		}
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), content, 0600)).To(Succeed())
+	}
+
+	createHost := func(node int, role airshipv1.BMHRole) (*metal3.BareMetalHost, *corev1.Secret, *corev1.Secret) {
+		bmh, networkData := testutil.CreateBMH(node, "default", role, 6)
+		bmh.TypeMeta = metav1.TypeMeta{APIVersion: metal3.GroupVersion.String(), Kind: "BareMetalHost"}
+		networkData.TypeMeta = metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"}
+		bmcSecret := testutil.CreateBMCAuthSecret(bmh.Name, bmh.Namespace, "root", "test")
+		bmh.Spec.BMC.CredentialsName = bmcSecret.Name
+		return bmh, networkData, bmcSecret
+	}
+
+	It("Should list the hosts that match a selector", func() {
+		for node := 0; node < 3; node++ {
+			bmh, networkData, bmcSecret := createHost(node, airshipv1.RoleControlPlane)
+			if node == 0 {
+				bmh.Labels[SipClusterNameLabel] = "subcluster-1"
+			}
+			writeInventory(fmt.Sprintf("%s.yaml", bmh.Name), bmh, networkData, bmcSecret)
+		}
+
+		inventory, err := NewFileInventory(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		hosts, err := inventory.ListHosts(labels.Everything())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hosts).To(HaveLen(3))
+
+		hosts, err = inventory.ListHosts(testutil.UnscheduledSelector())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hosts).To(HaveLen(2))
+		for _, bmh := range hosts {
+			Expect(bmh.Name).ToNot(Equal("node00"))
+		}
+	})
+
+	It("Should read network data and BMC credentials from Secrets", func() {
+		bmh, networkData, bmcSecret := createHost(1, airshipv1.RoleControlPlane)
+		// stringData is merged into data like the API server does
+		bmcSecret.StringData = map[string]string{"password": "override"}
+		bmh.Namespace = ""
+		bmcSecret.Namespace = ""
+		writeInventory("inventory.yaml", bmh, networkData, bmcSecret)
+
+		inventory, err := NewFileInventory(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		hosts, err := inventory.ListHosts(labels.Everything())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hosts).To(HaveLen(1))
+		Expect(hosts[0].Namespace).To(Equal("default"))
+
+		netData, err := inventory.GetNetworkData(hosts[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(netData.OpenstackNetworks).To(ContainElement(airshipv1.OpenstackNetwork{
+			ID: "oam-ipv4",
+			IP: "32.68.51.139",
+		}))
+
+		username, password, err := inventory.GetBMCCredentials(hosts[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(username).To(Equal("root"))
+		Expect(password).To(Equal("override"))
+	})
+
+	It("Should report Secrets that are missing from the inventory", func() {
+		bmh, _, _ := createHost(1, airshipv1.RoleControlPlane)
+		writeInventory("inventory.yaml", bmh)
+
+		inventory, err := NewFileInventory(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = inventory.GetNetworkData(*bmh)
+		Expect(err).To(MatchError(ErrSecretNotFound{Namespace: "default", Name: "node1-network-data"}))
+
+		_, _, err = inventory.GetBMCCredentials(*bmh)
+		Expect(err).To(MatchError(ErrSecretNotFound{Namespace: "default", Name: "node01-bmc-credentials"}))
+	})
+
+	It("Should schedule a SIPCluster without a Kubernetes cluster", func() {
+		for node := 0; node < 4; node++ {
+			role := airshipv1.RoleControlPlane
+			if node%2 == 1 {
+				role = airshipv1.RoleWorker
+			}
+			bmh, networkData, bmcSecret := createHost(node, role)
+			writeInventory(fmt.Sprintf("%s.yaml", bmh.Name), bmh, networkData, bmcSecret)
+		}
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", "default", 2, 2)
+
+		inventory, err := NewFileInventory(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		machineList := &MachineList{
+			NamespacedName: types.NamespacedName{
+				Name:      sipCluster.Name,
+				Namespace: sipCluster.Namespace,
+			},
+			Log: ctrl.Log.WithName("controllers").WithName("SIPCluster"),
+		}
+		Expect(machineList.Schedule(*sipCluster, inventory)).To(Succeed())
+		Expect(machineList.ExtrapolateServiceAddresses(*sipCluster, inventory)).To(Succeed())
+		Expect(machineList.ExtrapolateBMCAuth(*sipCluster, inventory)).To(Succeed())
+
+		Expect(machineList.Machines).To(HaveLen(4))
+		Expect(machineList.ReadyForScheduleCount[airshipv1.RoleControlPlane]).To(Equal(2))
+		Expect(machineList.ReadyForScheduleCount[airshipv1.RoleWorker]).To(Equal(2))
+		for _, machine := range machineList.Machines {
+			Expect(machine.ScheduleStatus).To(Equal(ToBeScheduled))
+			Expect(machine.Data.IPOnInterface).To(Equal(map[string]string{"oam-ipv4": "32.68.51.139"}))
+			Expect(machine.Data.BMCUsername).To(Equal("root"))
+			Expect(machine.Data.BMCPassword).To(Equal("test"))
+		}
+	})
+})
@@ -18,20 +18,31 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	kerror "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	airshipv1 "sipcluster/pkg/api/v1"
 	bmh "sipcluster/pkg/bmh"
+	"sipcluster/pkg/metrics"
 	airshipsvc "sipcluster/pkg/services"
 )
 
@@ -40,18 +51,39 @@ type SIPClusterReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
 	NamespacedName types.NamespacedName
+	// Recorder records events about the hosts and services of SIPClusters. No events are recorded when it is nil.
+	Recorder record.EventRecorder
 }
 
 const (
 	sipFinalizerName = "sip.airship.airshipit.org/finalizer"
+
+	// serviceReadinessRequeueAfter is how long to wait before checking again on services that are not serving yet.
+	// Deployment changes requeue the SIPCluster earlier; Endpoints are not watched.
+	serviceReadinessRequeueAfter = 15 * time.Second
+
+	// deprovisionRequeueAfter is how long to wait before checking again on BMHs that are being deprovisioned. Changes
+	// to their provisioning state requeue the SIPCluster earlier; the requeue enforces the bmh.DeprovisionTimeout.
+	deprovisionRequeueAfter = time.Minute
 )
 
+// ReasonServicesDefaulted is recorded on a SIPCluster whose stored services lack fields that SIP defaults in memory,
+// because the SIPCluster was not defaulted at admission.
+const ReasonServicesDefaulted = "ServicesDefaulted"
+
 // +kubebuilder:rbac:groups=airship.airshipit.org,resources=sipclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=airship.airshipit.org,resources=sipclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=airship.airshipit.org,resources=sipclusters/status,verbs=get;update;patch
 
-// +kubebuilder:rbac:groups="metal3.io",resources=baremetalhosts,verbs=get;update;patch;list
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;
+// +kubebuilder:rbac:groups="metal3.io",resources=baremetalhosts,verbs=get;update;patch;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="ipam.metal3.io",resources=ipclaims;ipaddresses,verbs=get;list
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *SIPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.NamespacedName = req.NamespacedName
@@ -66,39 +98,23 @@ func (r *SIPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
-	readyCondition := metav1.Condition{
-		Status:             metav1.ConditionFalse,
-		Reason:             airshipv1.ReasonTypeProgressing,
-		Type:               airshipv1.ConditionTypeReady,
-		ObservedGeneration: sip.GetGeneration(),
+	// The finalizer is added before any hosts are labeled or services are deployed, so that they are always released
+	// when the SIPCluster is deleted
+	if sip.ObjectMeta.DeletionTimestamp.IsZero() && !containsString(sip.ObjectMeta.Finalizers, sipFinalizerName) {
+		if err := r.addFinalizer(ctx, &sip); err != nil {
+			log.Error(err, "unable to add finalizer")
+			return ctrl.Result{Requeue: true}, err
+		}
 	}
 
-	apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-	if err := r.patchStatus(ctx, &sip); err != nil {
-		log.Error(err, "unable to set condition", "condition", readyCondition)
-		return ctrl.Result{Requeue: true}, err
-	}
+	r.defaultServices(&sip)
 
 	if !sip.ObjectMeta.DeletionTimestamp.IsZero() {
 		// SIPCluster is being deleted; handle the finalizers, then stop reconciling
-		// TODO(howell): add finalizers to the CRD
 		if containsString(sip.ObjectMeta.Finalizers, sipFinalizerName) {
-			result, err := r.handleFinalizers(ctx, sip)
+			result, err := r.handleFinalizers(ctx, &sip)
 			if err != nil {
-				readyCondition = metav1.Condition{
-					Status:             metav1.ConditionFalse,
-					Reason:             airshipv1.ReasonTypeUnableToDecommission,
-					Type:               airshipv1.ConditionTypeReady,
-					Message:            err.Error(),
-					ObservedGeneration: sip.GetGeneration(),
-				}
-
-				apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-				if patchStatusErr := r.patchStatus(ctx, &sip); err != nil {
-					err = kerror.NewAggregate([]error{err, patchStatusErr})
-					log.Error(err, "unable to set condition", "condition", readyCondition)
-				}
-
+				err = r.setNotReady(ctx, &sip, airshipv1.ReasonTypeUnableToDecommission, err)
 				log.Error(err, "unable to finalize")
 				return ctrl.Result{Requeue: true}, err
 			}
@@ -108,67 +124,57 @@ func (r *SIPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
-	machines, err := r.gatherVBMH(ctx, sip)
+	machines, err := r.schedule(ctx, &sip)
 	if err != nil {
-		readyCondition = metav1.Condition{
-			Status:             metav1.ConditionFalse,
-			Reason:             airshipv1.ReasonTypeUnschedulable,
-			Type:               airshipv1.ConditionTypeReady,
-			Message:            err.Error(),
-			ObservedGeneration: sip.GetGeneration(),
-		}
-
-		apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-		if patchStatusErr := r.patchStatus(ctx, &sip); err != nil {
-			err = kerror.NewAggregate([]error{err, patchStatusErr})
-			log.Error(err, "unable to set condition", "condition", readyCondition)
-		}
-
+		err = r.setNotReady(ctx, &sip, airshipv1.ReasonTypeUnschedulable, err)
 		log.Error(err, "unable to gather BMHs")
 		return ctrl.Result{Requeue: true}, err
 	}
 
-	err = r.deployInfra(sip, machines, log)
+	setPhase(&sip, airshipv1.PhaseServicesDeploying)
+	services, err := r.deployInfra(ctx, &sip, machines, log)
 	if err != nil {
-		readyCondition = metav1.Condition{
-			Status:             metav1.ConditionFalse,
-			Reason:             airshipv1.ReasonTypeInfraServiceFailure,
-			Type:               airshipv1.ConditionTypeReady,
-			Message:            err.Error(),
-			ObservedGeneration: sip.GetGeneration(),
-		}
-
-		apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-		if patchStatusErr := r.patchStatus(ctx, &sip); err != nil {
-			err = kerror.NewAggregate([]error{err, patchStatusErr})
-			log.Error(err, "unable to set condition", "condition", readyCondition)
-		}
-
+		err = r.setNotReady(ctx, &sip, deployFailureReason(err), err)
 		log.Error(err, "unable to deploy infrastructure services")
 		return ctrl.Result{Requeue: true}, err
 	}
 
+	sip.Status.Services = mergeServiceConditions(sip.Status.Services, services)
+	completePhase(&sip, airshipv1.PhaseServicesDeploying)
+
+	setPhase(&sip, airshipv1.PhaseLabeling)
 	err = r.finish(sip, machines)
 	if err != nil {
-		readyCondition = metav1.Condition{
+		err = r.setNotReady(ctx, &sip, airshipv1.ReasonTypeUnableToApplyLabels, err)
+		log.Error(err, "unable to finish reconciliation")
+		return ctrl.Result{Requeue: true}, err
+	}
+	completePhase(&sip, airshipv1.PhaseLabeling)
+
+	// The SIPCluster stays in the Labeling phase until its services are serving
+	sip.Status.Nodes = machines.NodeStatus(sip)
+	if unavailable := unavailableServices(services); len(unavailable) > 0 {
+		readyCondition := metav1.Condition{
 			Status:             metav1.ConditionFalse,
-			Reason:             airshipv1.ReasonTypeUnableToApplyLabels,
+			Reason:             airshipv1.ReasonTypeServicesUnavailable,
 			Type:               airshipv1.ConditionTypeReady,
-			Message:            err.Error(),
+			Message:            "waiting for services to become ready: " + strings.Join(unavailable, ", "),
 			ObservedGeneration: sip.GetGeneration(),
 		}
 
 		apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-		if patchStatusErr := r.patchStatus(ctx, &sip); err != nil {
-			err = kerror.NewAggregate([]error{err, patchStatusErr})
+		if err = r.patchStatus(ctx, &sip); err != nil {
 			log.Error(err, "unable to set condition", "condition", readyCondition)
+			return ctrl.Result{Requeue: true}, err
 		}
 
-		log.Error(err, "unable to finish reconciliation")
-		return ctrl.Result{Requeue: true}, err
+		log.Info("waiting for services to become ready", "services", unavailable)
+		return ctrl.Result{RequeueAfter: serviceReadinessRequeueAfter}, nil
 	}
 
-	readyCondition = metav1.Condition{
+	completePhase(&sip, airshipv1.PhaseReady)
+
+	readyCondition := metav1.Condition{
 		Status:             metav1.ConditionTrue,
 		Reason:             airshipv1.ReasonTypeReconciliationSucceeded,
 		Type:               airshipv1.ConditionTypeReady,
@@ -176,7 +182,7 @@ func (r *SIPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
-	if patchStatusErr := r.patchStatus(ctx, &sip); err != nil {
+	if patchStatusErr := r.patchStatus(ctx, &sip); patchStatusErr != nil {
 		err = kerror.NewAggregate([]error{err, patchStatusErr})
 		log.Error(err, "unable to set condition", "condition", readyCondition)
 
@@ -186,6 +192,102 @@ func (r *SIPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, nil
 }
 
+// setNotReady sets the Ready condition of the SIPCluster to false, with the reason and the message of err, and patches
+// its status. It returns err, aggregated with the error of patching the status if that fails.
+func (r *SIPClusterReconciler) setNotReady(ctx context.Context, sip *airshipv1.SIPCluster, reason string,
+	err error) error {
+	if patchStatusErr := r.setReady(ctx, sip, metav1.ConditionFalse, reason, err.Error()); patchStatusErr != nil {
+		return kerror.NewAggregate([]error{err, patchStatusErr})
+	}
+	return err
+}
+
+// setReady sets the Ready condition of the SIPCluster and patches its status. It returns the error of patching the
+// status.
+func (r *SIPClusterReconciler) setReady(ctx context.Context, sip *airshipv1.SIPCluster, status metav1.ConditionStatus,
+	reason, message string) error {
+	readyCondition := metav1.Condition{
+		Status:             status,
+		Reason:             reason,
+		Type:               airshipv1.ConditionTypeReady,
+		Message:            message,
+		ObservedGeneration: sip.GetGeneration(),
+	}
+
+	apimeta.SetStatusCondition(&sip.Status.Conditions, readyCondition)
+	err := r.patchStatus(ctx, sip)
+	if err != nil {
+		logr.FromContext(ctx).Error(err, "unable to set condition", "condition", readyCondition)
+	}
+	return err
+}
+
+// schedule runs the Scheduling phase, unless the BMHs that were scheduled for the current generation of the
+// SIPCluster can be reused. Scheduling is the only phase whose result is not persisted outside of the status, so it is
+// the only phase that is skipped on resume. Deploying services and labeling BMHs are idempotent and are re-run on
+// every reconcile, since BMH, Secret and ConfigMap changes do not bump the generation of the SIPCluster.
+func (r *SIPClusterReconciler) schedule(ctx context.Context, sip *airshipv1.SIPCluster) (*bmh.MachineList, error) {
+	log := logr.FromContext(ctx)
+
+	machines, err := r.resumeVBMH(ctx, *sip)
+	if err != nil {
+		log.Info("unable to reuse the scheduled BMHs, scheduling again", "reason", err.Error())
+	}
+	if machines != nil {
+		return machines, nil
+	}
+
+	setPhase(sip, airshipv1.PhaseScheduling)
+	start := time.Now()
+	machines, err = r.gatherVBMH(ctx, *sip)
+	metrics.ObserveSchedule(start, err, scheduleFailureReason(err))
+	if err != nil {
+		return nil, err
+	}
+
+	// The selected BMHs are recorded, so that a failure in a later phase does not select different ones
+	sip.Status.Nodes = machines.NodeStatus(*sip)
+	completePhase(sip, airshipv1.PhaseScheduled)
+	if err = r.patchStatus(ctx, sip); err != nil {
+		log.Error(err, "unable to record scheduled BMHs")
+		return nil, err
+	}
+	return machines, nil
+}
+
+// scheduleFailureReason returns the reason that a schedule attempt failed with err is counted under.
+func scheduleFailureReason(err error) string {
+	switch err.(type) {
+	case bmh.ErrorUnableToFullySchedule, bmh.ErrNoHostsAvailable:
+		return metrics.ScheduleFailureInsufficientHosts
+	}
+	return metrics.ScheduleFailureError
+}
+
+// setPhase records the phase that the SIPCluster enters. Entering the Scheduling phase starts over, for a new
+// generation or because the scheduled BMHs can no longer be used, so the phases completed for an earlier schedule are
+// forgotten and the SIPCluster is not ready until they complete again. The Ready condition is left as it is when a
+// reconcile resumes, so that BMH, Secret and ConfigMap events do not make a ready SIPCluster flap.
+func setPhase(sip *airshipv1.SIPCluster, phase airshipv1.SIPClusterPhase) {
+	sip.Status.Phase = phase
+	if phase == airshipv1.PhaseScheduling {
+		sip.Status.LastSuccessfulPhase = ""
+		apimeta.SetStatusCondition(&sip.Status.Conditions, metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             airshipv1.ReasonTypeProgressing,
+			Type:               airshipv1.ConditionTypeReady,
+			ObservedGeneration: sip.GetGeneration(),
+		})
+	}
+}
+
+// completePhase records that the SIPCluster completed a phase for its current generation.
+func completePhase(sip *airshipv1.SIPCluster, phase airshipv1.SIPClusterPhase) {
+	sip.Status.Phase = phase
+	sip.Status.LastSuccessfulPhase = phase
+	sip.Status.ObservedGeneration = sip.GetGeneration()
+}
+
 func (r *SIPClusterReconciler) patchStatus(ctx context.Context, sip *airshipv1.SIPCluster) error {
 	key := client.ObjectKeyFromObject(sip)
 	latest := &airshipv1.SIPCluster{}
@@ -198,24 +300,68 @@ func (r *SIPClusterReconciler) patchStatus(ctx context.Context, sip *airshipv1.S
 }
 
 func (r *SIPClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &metal3.BareMetalHost{}, hostSecretsIndex,
+		hostSecrets); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &airshipv1.SIPCluster{}, sshPrivateKeysIndex,
+		sshPrivateKeys); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&airshipv1.SIPCluster{}, builder.WithPredicates(
-			predicate.GenerationChangedPredicate{},
+			predicate.Or(predicate.GenerationChangedPredicate{}, deletionPredicate),
 		)).
+		// Deployments are owned by their SIPCluster, so their rollout progress updates its readiness
+		Owns(&appsv1.Deployment{}).
+		Watches(&source.Kind{Type: &metal3.BareMetalHost{}}, handler.EnqueueRequestsFromMapFunc(r.sipClustersForBMH),
+			builder.WithPredicates(hostChangedPredicate)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.sipClustersForSecret),
+			builder.WithPredicates(secretChangedPredicate)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.sipClustersForConfigMap)).
 		Complete(r)
 }
 
-func (r *SIPClusterReconciler) handleFinalizers(ctx context.Context, sip airshipv1.SIPCluster) (ctrl.Result, error) {
+func (r *SIPClusterReconciler) handleFinalizers(ctx context.Context, sip *airshipv1.SIPCluster) (ctrl.Result, error) {
 	log := logr.FromContext(ctx)
-	err := r.finalize(ctx, sip)
+	err := r.finalize(ctx, *sip)
+	// Waiting on BMHs to be deprovisioned is progress rather than a failure, so it is not retried with a backoff
+	var deprovisioning bmh.ErrHostsDeprovisioning
+	if errors.As(err, &deprovisioning) {
+		log.Info("waiting for BMHs to be deprovisioned", "BMHs", deprovisioning.Hosts)
+		return ctrl.Result{RequeueAfter: deprovisionRequeueAfter},
+			r.setReady(ctx, sip, metav1.ConditionFalse, airshipv1.ReasonTypeDeprovisioning, err.Error())
+	}
 	if err != nil {
 		log.Error(err, "unable to finalize")
 		return ctrl.Result{}, err
 	}
 
 	// remove the finalizer from the list and update it.
+	patch := client.MergeFromWithOptions(sip.DeepCopy(), client.MergeFromWithOptimisticLock{})
 	sip.ObjectMeta.Finalizers = removeString(sip.ObjectMeta.Finalizers, sipFinalizerName)
-	return ctrl.Result{}, r.Update(context.Background(), &sip)
+	return ctrl.Result{}, r.Patch(ctx, sip, patch)
+}
+
+// addFinalizer adds the SIP finalizer to a SIPCluster.
+func (r *SIPClusterReconciler) addFinalizer(ctx context.Context, sip *airshipv1.SIPCluster) error {
+	patch := client.MergeFromWithOptions(sip.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	sip.ObjectMeta.Finalizers = append(sip.ObjectMeta.Finalizers, sipFinalizerName)
+	return r.Patch(ctx, sip, patch)
+}
+
+// defaultServices fills in the fields of the services of a SIPCluster that SIP is unable to render them without, and
+// records an event when the stored spec lacks any of them, so that the spec that SIP uses differs from the stored one.
+// The other defaults of the defaulting webhook are not applied, so that SIP does not place SIPClusters that were
+// stored without them differently.
+func (r *SIPClusterReconciler) defaultServices(sip *airshipv1.SIPCluster) {
+	stored := sip.Spec.Services.DeepCopy()
+	sip.DefaultServices()
+	if r.Recorder != nil && !equality.Semantic.DeepEqual(*stored, sip.Spec.Services) {
+		r.Recorder.Event(sip, corev1.EventTypeNormal, ReasonServicesDefaulted,
+			"Defaulted service fields that are not stored in the SIPCluster; deploy the defaulting webhook to store them")
+	}
 }
 
 // containsString is a helper function to check whether the string s is in the slice
@@ -275,26 +421,28 @@ func (r *SIPClusterReconciler) gatherVBMH(ctx context.Context, sip airshipv1.SIP
 	machines := &bmh.MachineList{
 		Log:            logger.WithName("machines"),
 		NamespacedName: r.NamespacedName,
+		Recorder:       r.Recorder,
 	}
+	inventory := bmh.NewKubernetesInventory(r.Client)
 	// TODO : this is a loop until we succeed or cannot find a schedule
 	for {
 		logger.Info("gathering machines", "machines", machines.String())
 
 		// NOTE: Schedule executes the scheduling algorithm to find hosts that meet the topology and role
 		// constraints.
-		err := machines.Schedule(sip, r.Client)
+		err := machines.Schedule(sip, inventory)
 		if err != nil {
 			return machines, err
 		}
 
-		if err = machines.ExtrapolateServiceAddresses(sip, r.Client); err != nil {
+		if err = machines.ExtrapolateServiceAddresses(sip, inventory); err != nil {
 			logger.Error(err, "unable to retrieve infrastructure service IP addresses from selected BMHs."+
 				"Selecting replacement hosts.")
 
 			continue
 		}
 
-		if err = machines.ExtrapolateBMCAuth(sip, r.Client); err != nil {
+		if err = machines.ExtrapolateBMCAuth(sip, inventory); err != nil {
 			logger.Error(err, "unable to retrieve BMC auth info from selected BMHs. Selecting replacement"+
 				"hosts.")
 
@@ -307,20 +455,92 @@ func (r *SIPClusterReconciler) gatherVBMH(ctx context.Context, sip airshipv1.SIP
 	return machines, nil
 }
 
-func (r *SIPClusterReconciler) deployInfra(sip airshipv1.SIPCluster, machines *bmh.MachineList,
-	logger logr.Logger) error {
-	newServiceSet := airshipsvc.NewServiceSet(logger, sip, machines, r.Client)
+// resumeVBMH selects the BMHs that were recorded when the current generation of the SIPCluster was scheduled again.
+// It returns no machines if the current generation has not been scheduled yet.
+func (r *SIPClusterReconciler) resumeVBMH(ctx context.Context, sip airshipv1.SIPCluster) (*bmh.MachineList, error) {
+	if sip.Status.ObservedGeneration != sip.GetGeneration() || sip.Status.LastSuccessfulPhase == "" {
+		return nil, nil
+	}
+
+	logger := logr.FromContext(ctx)
+	machines := &bmh.MachineList{
+		Log:            logger.WithName("machines"),
+		NamespacedName: r.NamespacedName,
+		Recorder:       r.Recorder,
+	}
+	inventory := bmh.NewKubernetesInventory(r.Client)
+	if err := machines.Restore(sip, sip.Status.Nodes, inventory); err != nil {
+		return nil, err
+	}
+	if err := machines.ExtrapolateServiceAddresses(sip, inventory); err != nil {
+		return nil, err
+	}
+	if err := machines.ExtrapolateBMCAuth(sip, inventory); err != nil {
+		return nil, err
+	}
+
+	logger.Info("resuming from the scheduled BMHs", "last successful phase", sip.Status.LastSuccessfulPhase)
+	return machines, nil
+}
+
+// deployInfra allocates the node ports of the infrastructure services of the SIPCluster, deploys the services and
+// returns their status.
+func (r *SIPClusterReconciler) deployInfra(ctx context.Context, sip *airshipv1.SIPCluster, machines *bmh.MachineList,
+	logger logr.Logger) ([]airshipv1.ServiceStatus, error) {
+	if err := airshipsvc.AllocateNodePorts(ctx, sip, r.Client); err != nil {
+		return nil, err
+	}
+
+	newServiceSet := airshipsvc.NewServiceSet(logger, *sip, machines, r.Client)
 	serviceList, err := newServiceSet.ServiceList()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, svc := range serviceList {
-		err := svc.Deploy()
-		if err != nil {
-			return err
+	if err = newServiceSet.Deploy(serviceList, r.Recorder); err != nil {
+		return nil, err
+	}
+	if err = newServiceSet.Prune(serviceList); err != nil {
+		return nil, err
+	}
+	return newServiceSet.Status(serviceList)
+}
+
+// deployFailureReason returns the reason of the Ready condition of a SIPCluster whose services failed to deploy with
+// err.
+func deployFailureReason(err error) string {
+	if _, ok := err.(airshipsvc.ErrNodePortConflict); ok {
+		return airshipv1.ReasonTypeNodePortConflict
+	}
+	return airshipv1.ReasonTypeInfraServiceFailure
+}
+
+// unavailableServices returns the names of the services that are not ready.
+func unavailableServices(services []airshipv1.ServiceStatus) []string {
+	unavailable := []string{}
+	for _, svc := range services {
+		if !svc.Ready {
+			unavailable = append(unavailable, svc.Name)
+		}
+	}
+	return unavailable
+}
+
+// mergeServiceConditions sets the conditions of the current service statuses on the previous conditions of the same
+// services, so that conditions keep their transition time while their status does not change.
+func mergeServiceConditions(previous, current []airshipv1.ServiceStatus) []airshipv1.ServiceStatus {
+	for i := range current {
+		for _, prev := range previous {
+			if prev.Name != current[i].Name {
+				continue
+			}
+			conditions := append([]metav1.Condition{}, prev.Conditions...)
+			for _, condition := range current[i].Conditions {
+				apimeta.SetStatusCondition(&conditions, condition)
+			}
+			current[i].Conditions = conditions
 		}
 	}
-	return nil
+	return current
 }
 
 /*
@@ -337,17 +557,28 @@ Such as i'e what are we doing with the lables on the BMH's
 **/
 func (r *SIPClusterReconciler) finalize(ctx context.Context, sip airshipv1.SIPCluster) error {
 	logger := logr.FromContext(ctx)
-	machines := &bmh.MachineList{}
+	machines := &bmh.MachineList{
+		Log:      logger.WithName("machines"),
+		Recorder: r.Recorder,
+	}
 	serviceSet := airshipsvc.NewServiceSet(logger, sip, machines, r.Client)
+	if sip.Spec.ReclaimPolicy == airshipv1.ReclaimPolicyRetain {
+		// The BMHs stay labeled; the services only have to outlive the SIPCluster
+		logger.Info("retaining BMHs and services of SIPCluster")
+		return serviceSet.Orphan()
+	}
+
 	serviceList, err := serviceSet.ServiceList()
 	if err != nil {
 		return err
 	}
+	// Every service is finalized, so that the decommission status reports all of the objects that remain
+	finalizeErrs := []error{}
 	for _, svc := range serviceList {
-		err = svc.Finalize()
-		if err != nil {
-			return err
-		}
+		finalizeErrs = append(finalizeErrs, svc.Finalize())
+	}
+	if err = kerror.NewAggregate(finalizeErrs); err != nil {
+		return err
 	}
 	err = serviceSet.Finalize()
 	if err != nil {
@@ -360,15 +591,21 @@ func (r *SIPClusterReconciler) finalize(ctx context.Context, sip airshipv1.SIPCl
 	// If Not complete schedule , then throw an error.
 	logger.Info("finalize sip machines", "machines", machines.String())
 	// Update the list of  Machines.
-	err = machines.GetCluster(sip, r.Client)
+	err = machines.GetCluster(sip, bmh.NewKubernetesInventory(r.Client))
 	if err != nil {
 		return err
 	}
 
-	// Placeholder - unsure whether this is what we want
-	err = machines.RemoveLabels(r.Client)
-	if err != nil {
-		return err
+	if sip.Spec.ReclaimPolicy == airshipv1.ReclaimPolicyDeprovision {
+		deprovisioning, err := machines.Deprovision(sip, r.Client)
+		if err != nil {
+			return err
+		}
+		if len(deprovisioning) > 0 {
+			return bmh.ErrHostsDeprovisioning{Hosts: deprovisioning}
+		}
+		return nil
 	}
-	return nil
+
+	return machines.RemoveLabels(sip, r.Client)
 }
@@ -26,12 +26,15 @@ import (
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	airshipv1 "sipcluster/pkg/api/v1"
 	bmhpkg "sipcluster/pkg/bmh"
+	"sipcluster/pkg/metrics"
 	"sipcluster/testutil"
 )
 
@@ -92,6 +95,30 @@ var _ = Describe("SIPCluster controller", func() {
 
 				return testutil.CompareLabels(expectedLabels, bmh.GetLabels())
 			}, 30, 5).Should(Succeed())
+
+			By("Reporting the scheduled nodes and deployed services")
+			Eventually(func() bool {
+				var sipCR airshipv1.SIPCluster
+				Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      clusterName,
+					Namespace: testNamespace,
+				}, &sipCR)).Should(Succeed())
+
+				return len(sipCR.Status.Nodes) == len(nodes) &&
+					len(sipCR.Status.Services) == len(sipCR.Spec.Services.JumpHost)+
+						len(sipCR.Spec.Services.LoadBalancerControlPlane)+len(sipCR.Spec.Services.LoadBalancerWorker)
+			}, 30, 5).Should(BeTrue())
+
+			By("Waiting in the Labeling phase for the services to serve")
+			// The test environment does not run the Deployment controller, so the services never become ready
+			var sipCR airshipv1.SIPCluster
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Name:      clusterName,
+				Namespace: testNamespace,
+			}, &sipCR)).Should(Succeed())
+			Expect(sipCR.Status.Phase).To(Equal(airshipv1.PhaseLabeling))
+			Expect(sipCR.Status.LastSuccessfulPhase).To(Equal(airshipv1.PhaseLabeling))
+			Expect(sipCR.Status.ObservedGeneration).To(Equal(sipCR.GetGeneration()))
 		})
 
 		It("Should not schedule nodes when there is an insufficient number of available ControlPlane nodes", func() {
@@ -439,4 +466,124 @@ var _ = Describe("SIPCluster controller", func() {
 			})
 		})
 	})
+
+	Context("When it detects a SIPCluster without the SIP finalizer", func() {
+		It("Should add the finalizer", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-test-finalizer", testNamespace, 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), sipCluster)).Should(Succeed())
+
+			Eventually(func() []string {
+				var sipCR airshipv1.SIPCluster
+				Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      sipCluster.Name,
+					Namespace: testNamespace,
+				}, &sipCR)).To(Succeed())
+				return sipCR.GetFinalizers()
+			}, 30, 5).Should(ContainElement(sipFinalizerName))
+		})
+	})
+
+	Context("When the BMC credentials of its BMHs change", func() {
+		It("Should schedule the nodes once the credentials exist", func() {
+			By("Creating the BMC credential Secrets after the SIPCluster")
+
+			nodes := []airshipv1.BMHRole{airshipv1.RoleControlPlane, airshipv1.RoleWorker}
+			bmcSecrets := []*corev1.Secret{}
+			for node, role := range nodes {
+				bmh, networkData := testutil.CreateBMH(node, testNamespace, role, 6)
+				bmcSecret := testutil.CreateBMCAuthSecret(bmh.Name, bmh.Namespace, "root", "test")
+				bmh.Spec.BMC.CredentialsName = bmcSecret.Name
+				bmcSecrets = append(bmcSecrets, bmcSecret)
+
+				Expect(k8sClient.Create(context.Background(), bmh)).Should(Succeed())
+				Expect(k8sClient.Create(context.Background(), networkData)).Should(Succeed())
+			}
+
+			clusterName := "subcluster-test-watch"
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster(clusterName, testNamespace, 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), sipCluster)).Should(Succeed())
+
+			key := types.NamespacedName{Name: clusterName, Namespace: testNamespace}
+			Eventually(func() bool {
+				var sipCR airshipv1.SIPCluster
+				Expect(k8sClient.Get(context.Background(), key, &sipCR)).To(Succeed())
+				return apimeta.IsStatusConditionFalse(sipCR.Status.Conditions, airshipv1.ConditionTypeReady)
+			}, 30, 5).Should(BeTrue())
+
+			for _, bmcSecret := range bmcSecrets {
+				Expect(k8sClient.Create(context.Background(), bmcSecret)).Should(Succeed())
+			}
+
+			// Poll BMHs until SIP has scheduled them to the SIP cluster
+			Eventually(func() error {
+				expectedLabels := labels.SelectorFromSet(bmhpkg.GetClusterLabels(*sipCluster))
+
+				var bmh metal3.BareMetalHost
+				for node := range nodes {
+					Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+						Name:      fmt.Sprintf("node0%d", node),
+						Namespace: testNamespace,
+					}, &bmh)).Should(Succeed())
+					if err := testutil.CompareLabels(expectedLabels, bmh.GetLabels()); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, 30, 5).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("SIPCluster phases", func() {
+	It("Should only reset the Ready condition when scheduling starts over", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", testNamespace, 1, 1)
+		apimeta.SetStatusCondition(&sipCluster.Status.Conditions, metav1.Condition{
+			Status: metav1.ConditionTrue,
+			Reason: airshipv1.ReasonTypeReconciliationSucceeded,
+			Type:   airshipv1.ConditionTypeReady,
+		})
+
+		for _, phase := range []airshipv1.SIPClusterPhase{airshipv1.PhaseServicesDeploying, airshipv1.PhaseLabeling} {
+			setPhase(sipCluster, phase)
+			Expect(apimeta.IsStatusConditionTrue(sipCluster.Status.Conditions, airshipv1.ConditionTypeReady)).To(BeTrue())
+		}
+
+		setPhase(sipCluster, airshipv1.PhaseScheduling)
+		ready := apimeta.FindStatusCondition(sipCluster.Status.Conditions, airshipv1.ConditionTypeReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(airshipv1.ReasonTypeProgressing))
+		Expect(sipCluster.Status.LastSuccessfulPhase).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Schedule failures", func() {
+	It("Should count a lack of free BMHs as InsufficientHosts", func() {
+		Expect(scheduleFailureReason(bmhpkg.ErrNoHostsAvailable{})).To(Equal(metrics.ScheduleFailureInsufficientHosts))
+		Expect(scheduleFailureReason(bmhpkg.ErrorUnableToFullySchedule{})).To(
+			Equal(metrics.ScheduleFailureInsufficientHosts))
+		Expect(scheduleFailureReason(fmt.Errorf("unable to list BMHs"))).To(Equal(metrics.ScheduleFailureError))
+	})
+})
+
+var _ = Describe("Service defaults", func() {
+	It("Should only default the service fields that SIP is unable to render services without", func() {
+		sipCluster, _ := testutil.CreateSIPCluster("subcluster-1", testNamespace, 1, 1)
+		controlPlaneSpec := sipCluster.Spec.Nodes[airshipv1.RoleControlPlane]
+		controlPlaneSpec.TopologyKey = ""
+		sipCluster.Spec.Nodes[airshipv1.RoleControlPlane] = controlPlaneSpec
+		sipCluster.Spec.Services.JumpHost[0].Image = ""
+		recorder := record.NewFakeRecorder(2)
+		reconciler := &SIPClusterReconciler{Recorder: recorder}
+
+		reconciler.defaultServices(sipCluster)
+		Expect(sipCluster.Spec.Services.JumpHost[0].Image).To(Equal(airshipv1.DefaultJumpHostImage))
+		Expect(sipCluster.Spec.Services.LoadBalancerControlPlane[0].NodeLabels).To(BeNil())
+		Expect(sipCluster.Spec.Nodes[airshipv1.RoleControlPlane].TopologyKey).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring(ReasonServicesDefaulted)))
+
+		reconciler.defaultServices(sipCluster)
+		Expect(recorder.Events).ToNot(Receive())
+	})
 })
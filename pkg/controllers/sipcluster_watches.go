@@ -0,0 +1,263 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	bmh "sipcluster/pkg/bmh"
+	airshipsvc "sipcluster/pkg/services"
+)
+
+// The functions in this file map changes to the objects that a SIPCluster is built from, i.e. its BMHs, their network
+// data and BMC credential Secrets, the jump host SSH private key Secrets and the load balancer template ConfigMaps,
+// back to the SIPClusters that need to be reconciled.
+
+const (
+	// hostSecretsIndex indexes BMHs by the namespace/name of their network data and BMC credential Secrets.
+	hostSecretsIndex = "sip.airshipit.org/host-secrets"
+	// sshPrivateKeysIndex indexes SIPClusters by the names of their jump host SSH private key Secrets.
+	sshPrivateKeysIndex = "sip.airshipit.org/ssh-private-keys"
+)
+
+// secretChangedPredicate filters out Secret updates that only change metadata, such as the resourceVersion and
+// managedFields updates of controllers that touch Secrets without changing their data.
+var secretChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		newSecret, ok := e.ObjectNew.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		return !reflect.DeepEqual(oldSecret.Data, newSecret.Data) ||
+			!reflect.DeepEqual(oldSecret.StringData, newSecret.StringData)
+	},
+}
+
+// hostChangedPredicate filters out BMH updates that cannot affect scheduling, such as power status updates.
+// Scheduling depends on the labels, annotations and spec of a BMH, and deprovisioning on its provisioning state.
+var hostChangedPredicate = predicate.Or(
+	predicate.GenerationChangedPredicate{},
+	predicate.AnnotationChangedPredicate{},
+	predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+	},
+	predicate.Funcs{
+		// BMHs that are deprovisioned on deletion of their SIPCluster are released once they are ready again
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldHost, ok := e.ObjectOld.(*metal3.BareMetalHost)
+			if !ok {
+				return false
+			}
+			newHost, ok := e.ObjectNew.(*metal3.BareMetalHost)
+			if !ok {
+				return false
+			}
+			return oldHost.Status.Provisioning.State != newHost.Status.Provisioning.State
+		},
+	},
+)
+
+// deletionPredicate passes updates that mark an object for deletion, so that finalization starts without relying on
+// the API server to bump its generation.
+var deletionPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return false
+		}
+		return e.ObjectOld.GetDeletionTimestamp().IsZero() && !e.ObjectNew.GetDeletionTimestamp().IsZero()
+	},
+}
+
+// sipClustersForBMH returns the SIPCluster that a BMH is scheduled to or, for a BMH that is not scheduled, every
+// SIPCluster that the BMH could be scheduled to.
+func (r *SIPClusterReconciler) sipClustersForBMH(obj client.Object) []reconcile.Request {
+	host, ok := obj.(*metal3.BareMetalHost)
+	if !ok {
+		return nil
+	}
+
+	sips := &airshipv1.SIPClusterList{}
+	if err := r.List(context.Background(), sips); err != nil {
+		ctrl.Log.WithName("sipcluster").Error(err, "unable to list SIPClusters")
+		return nil
+	}
+
+	return toRequests(sipClustersForHost(*host, sips.Items))
+}
+
+// sipClustersForSecret returns the SIPClusters of the BMHs that reference a Secret as network data or BMC credentials,
+// and the SIPClusters whose jump hosts mount the Secret as SSH private keys. Both are looked up through the field
+// indexes on the Secret names, since every Secret in the cluster is watched.
+func (r *SIPClusterReconciler) sipClustersForSecret(obj client.Object) []reconcile.Request {
+	log := ctrl.Log.WithName("sipcluster")
+
+	sips := &airshipv1.SIPClusterList{}
+	if err := r.List(context.Background(), sips, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{sshPrivateKeysIndex: obj.GetName()}); err != nil {
+		log.Error(err, "unable to list SIPClusters")
+		return nil
+	}
+
+	clusters := map[types.NamespacedName]bool{}
+	for _, sip := range sips.Items {
+		clusters[types.NamespacedName{Namespace: sip.Namespace, Name: sip.Name}] = true
+	}
+
+	hosts := &metal3.BareMetalHostList{}
+	if err := r.List(context.Background(), hosts,
+		client.MatchingFields{hostSecretsIndex: obj.GetNamespace() + "/" + obj.GetName()}); err != nil {
+		log.Error(err, "unable to list BMHs")
+		return toRequests(clusters)
+	}
+
+	// Every SIPCluster is only needed for hosts that are not scheduled yet
+	var allSIPs *airshipv1.SIPClusterList
+	for _, host := range hosts.Items {
+		if _, scheduled := host.Labels[bmh.SipClusterNameLabel]; !scheduled && allSIPs == nil {
+			allSIPs = &airshipv1.SIPClusterList{}
+			if err := r.List(context.Background(), allSIPs); err != nil {
+				log.Error(err, "unable to list SIPClusters")
+				return toRequests(clusters)
+			}
+		}
+
+		var candidates []airshipv1.SIPCluster
+		if allSIPs != nil {
+			candidates = allSIPs.Items
+		}
+		for cluster := range sipClustersForHost(host, candidates) {
+			clusters[cluster] = true
+		}
+	}
+
+	return toRequests(clusters)
+}
+
+// sipClustersForConfigMap returns the SIPClusters in the namespace of a load balancer template ConfigMap that deploy
+// the load balancers it configures.
+func (r *SIPClusterReconciler) sipClustersForConfigMap(obj client.Object) []reconcile.Request {
+	if obj.GetName() != airshipsvc.ControlPlaneTemplateConfigMapName &&
+		obj.GetName() != airshipsvc.WorkerTemplateConfigMapName {
+		return nil
+	}
+
+	sips := &airshipv1.SIPClusterList{}
+	if err := r.List(context.Background(), sips, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.Log.WithName("sipcluster").Error(err, "unable to list SIPClusters")
+		return nil
+	}
+
+	clusters := map[types.NamespacedName]bool{}
+	for _, sip := range sips.Items {
+		if (obj.GetName() == airshipsvc.ControlPlaneTemplateConfigMapName &&
+			len(sip.Spec.Services.LoadBalancerControlPlane) > 0) ||
+			(obj.GetName() == airshipsvc.WorkerTemplateConfigMapName && len(sip.Spec.Services.LoadBalancerWorker) > 0) {
+			clusters[types.NamespacedName{Namespace: sip.Namespace, Name: sip.Name}] = true
+		}
+	}
+
+	return toRequests(clusters)
+}
+
+// sipClustersForHost returns the SIPCluster that a BMH is scheduled to or, for a BMH that is not scheduled, the
+// SIPClusters that have a node set whose labelSelector matches the BMH.
+func sipClustersForHost(host metal3.BareMetalHost, sips []airshipv1.SIPCluster) map[types.NamespacedName]bool {
+	clusters := map[types.NamespacedName]bool{}
+	if name, ok := host.Labels[bmh.SipClusterNameLabel]; ok {
+		clusters[types.NamespacedName{Namespace: host.Labels[bmh.SipClusterNamespaceLabel], Name: name}] = true
+		return clusters
+	}
+
+	for _, sip := range sips {
+		for _, nodeSet := range sip.Spec.Nodes {
+			nodeSet := nodeSet
+			selector, err := metav1.LabelSelectorAsSelector(&nodeSet.LabelSelector)
+			if err != nil {
+				continue
+			}
+			if selector.Matches(labels.Set(host.Labels)) {
+				clusters[types.NamespacedName{Namespace: sip.Namespace, Name: sip.Name}] = true
+			}
+		}
+	}
+	return clusters
+}
+
+// hostSecrets returns the namespace/name of the Secrets that a BMH uses as its network data and BMC credentials. It
+// is the indexer of the hostSecretsIndex.
+func hostSecrets(obj client.Object) []string {
+	host, ok := obj.(*metal3.BareMetalHost)
+	if !ok {
+		return nil
+	}
+
+	secrets := []string{}
+	if host.Spec.NetworkData != nil && host.Spec.NetworkData.Name != "" {
+		secrets = append(secrets, host.Spec.NetworkData.Namespace+"/"+host.Spec.NetworkData.Name)
+	}
+	if host.Spec.BMC.CredentialsName != "" {
+		secrets = append(secrets, host.Namespace+"/"+host.Spec.BMC.CredentialsName)
+	}
+	return secrets
+}
+
+// sshPrivateKeys returns the names of the Secrets that the jump hosts of a SIPCluster mount as SSH private keys. It
+// is the indexer of the sshPrivateKeysIndex.
+func sshPrivateKeys(obj client.Object) []string {
+	sip, ok := obj.(*airshipv1.SIPCluster)
+	if !ok {
+		return nil
+	}
+
+	secrets := []string{}
+	for _, jumpHost := range sip.Spec.Services.JumpHost {
+		if jumpHost.NodeSSHPrivateKeys != "" {
+			secrets = append(secrets, jumpHost.NodeSSHPrivateKeys)
+		}
+	}
+	return secrets
+}
+
+func toRequests(clusters map[types.NamespacedName]bool) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(clusters))
+	for cluster := range clusters {
+		requests = append(requests, reconcile.Request{NamespacedName: cluster})
+	}
+	return requests
+}
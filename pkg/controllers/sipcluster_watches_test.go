@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("SIPCluster watches", func() {
+	It("Should index BMHs by their network data and BMC credential Secrets", func() {
+		bmh, networkData := testutil.CreateBMH(1, testNamespace, airshipv1.RoleControlPlane, 6)
+		bmh.Spec.BMC.CredentialsName = "node01-bmc-credentials"
+
+		Expect(hostSecrets(bmh)).To(ConsistOf(
+			testNamespace+"/"+networkData.Name,
+			testNamespace+"/node01-bmc-credentials",
+		))
+	})
+
+	It("Should index SIPClusters by their jump host SSH private key Secrets", func() {
+		sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("subcluster-1", testNamespace, 1, 1)
+
+		Expect(sshPrivateKeys(sipCluster)).To(ConsistOf(nodeSSHPrivateKeys.Name))
+	})
+
+	It("Should only pass Secret updates that change data", func() {
+		secret := testutil.CreateBMCAuthSecret("node01", testNamespace, "root", "test")
+		updated := secret.DeepCopy()
+		updated.ResourceVersion = "2"
+		Expect(secretChangedPredicate.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: updated})).To(BeFalse())
+
+		updated.Data = map[string][]byte{"username": []byte("root"), "password": []byte("changed")}
+		Expect(secretChangedPredicate.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: updated})).To(BeTrue())
+		Expect(secretChangedPredicate.Create(event.CreateEvent{Object: &corev1.Secret{}})).To(BeTrue())
+	})
+})
@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	bmh "sipcluster/pkg/bmh"
+	"sipcluster/pkg/metrics"
+)
+
+// SIPInventoryReconciler reconciles a SIPInventory object
+type SIPInventoryReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=airship.airshipit.org,resources=sipinventories,verbs=get;list;watch
+// +kubebuilder:rbac:groups=airship.airshipit.org,resources=sipinventories/status,verbs=get;update;patch
+
+// +kubebuilder:rbac:groups="metal3.io",resources=baremetalhosts,verbs=get;list;watch
+
+func (r *SIPInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logr.FromContext(ctx)
+
+	inventory := airshipv1.SIPInventory{}
+	if err := r.Get(ctx, req.NamespacedName, &inventory); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.Inventory.Delete(req.Name)
+		}
+		log.Error(err, "unable to fetch SIPInventory")
+		return ctrl.Result{}, nil
+	}
+
+	groups, err := bmh.Capacity(inventory.Spec, bmh.NewKubernetesInventory(r.Client))
+	if err != nil {
+		readyCondition := metav1.Condition{
+			Status:             metav1.ConditionFalse,
+			Reason:             airshipv1.ReasonTypeUnableToCountHosts,
+			Type:               airshipv1.ConditionTypeReady,
+			Message:            err.Error(),
+			ObservedGeneration: inventory.GetGeneration(),
+		}
+
+		apimeta.SetStatusCondition(&inventory.Status.Conditions, readyCondition)
+		if patchStatusErr := r.patchStatus(ctx, &inventory); patchStatusErr != nil {
+			log.Error(patchStatusErr, "unable to set condition", "condition", readyCondition)
+		}
+
+		log.Error(err, "unable to count hosts")
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	readyCondition := metav1.Condition{
+		Status:             metav1.ConditionTrue,
+		Reason:             airshipv1.ReasonTypeReconciliationSucceeded,
+		Type:               airshipv1.ConditionTypeReady,
+		ObservedGeneration: inventory.GetGeneration(),
+	}
+
+	metrics.Inventory.Set(inventory.Name, groups)
+	inventory.Status.Groups = groups
+	apimeta.SetStatusCondition(&inventory.Status.Conditions, readyCondition)
+	if err = r.patchStatus(ctx, &inventory); err != nil {
+		log.Error(err, "unable to update inventory status")
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *SIPInventoryReconciler) patchStatus(ctx context.Context, inventory *airshipv1.SIPInventory) error {
+	key := client.ObjectKeyFromObject(inventory)
+	latest := &airshipv1.SIPInventory{}
+
+	if err := r.Client.Get(ctx, key, latest); err != nil {
+		return err
+	}
+
+	return r.Client.Status().Patch(ctx, inventory, client.MergeFrom(latest))
+}
+
+func (r *SIPInventoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&airshipv1.SIPInventory{}, builder.WithPredicates(
+			predicate.GenerationChangedPredicate{},
+		)).
+		// Every SIPInventory may count a BMH, so a change to any BMH refreshes all of them
+		Watches(&source.Kind{Type: &metal3.BareMetalHost{}}, handler.EnqueueRequestsFromMapFunc(r.allInventories)).
+		Complete(r)
+}
+
+func (r *SIPInventoryReconciler) allInventories(client.Object) []reconcile.Request {
+	inventories := &airshipv1.SIPInventoryList{}
+	if err := r.List(context.Background(), inventories); err != nil {
+		ctrl.Log.WithName("sipinventory").Error(err, "unable to list SIPInventories")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(inventories.Items))
+	for i := range inventories.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&inventories.Items[i]),
+		})
+	}
+	return requests
+}
@@ -94,8 +94,9 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).NotTo(HaveOccurred())
 
 	err = (&SIPClusterReconciler{
-		Client: k8sClient,
-		Scheme: scheme.Scheme,
+		Client:   k8sClient,
+		Scheme:   scheme.Scheme,
+		Recorder: k8sManager.GetEventRecorderFor("sipcluster-controller"),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
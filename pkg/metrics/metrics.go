@@ -0,0 +1,197 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the Prometheus metrics of SIP. They are registered with the controller-runtime registry
+// and served on the metrics endpoint of the manager.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+const namespace = "sip"
+
+// Reasons for which a schedule attempt fails
+const (
+	// ScheduleFailureInsufficientHosts means that there were no free BMHs, or not enough to fill a node set.
+	ScheduleFailureInsufficientHosts = "InsufficientHosts"
+	// ScheduleFailureError means that the scheduler was unable to read the inventory.
+	ScheduleFailureError = "Error"
+)
+
+// Reasons for which the scheduler excludes a BMH
+const (
+	// ExclusionNetworkData means that the service addresses of a BMH could not be read from its address source.
+	ExclusionNetworkData = "NetworkData"
+	// ExclusionBMCCredentials means that the BMC credentials of a BMH could not be read.
+	ExclusionBMCCredentials = "BMCCredentials"
+	// ExclusionTopologyDomain means that a BMH was in a topology domain that a node set already has a BMH in.
+	ExclusionTopologyDomain = "TopologyDomain"
+)
+
+var (
+	// ScheduleAttempts counts the attempts to schedule the BMHs of a SIPCluster.
+	ScheduleAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "schedule_attempts_total",
+		Help:      "Number of attempts to schedule the BMHs of a SIPCluster.",
+	})
+
+	// ScheduleFailures counts the schedule attempts that failed, by reason.
+	ScheduleFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "schedule_failures_total",
+		Help:      "Number of attempts to schedule the BMHs of a SIPCluster that failed, by reason.",
+	}, []string{"reason"})
+
+	// ScheduleDuration observes how long it takes to schedule the BMHs of a SIPCluster.
+	ScheduleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "schedule_duration_seconds",
+		Help:      "Time taken to schedule the BMHs of a SIPCluster.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// HostsExcluded counts the BMHs that the scheduler excluded, by reason. A BMH is counted once per schedule attempt,
+	// however many node sets or scheduling passes exclude it.
+	HostsExcluded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hosts_excluded_total",
+		Help:      "Number of BMHs that the scheduler excluded from a SIPCluster, by reason.",
+	}, []string{"reason"})
+
+	// ServiceDeployErrors counts the failures to deploy an infrastructure service, by service.
+	ServiceDeployErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "service_deploy_errors_total",
+		Help:      "Number of failures to deploy an infrastructure service of a SIPCluster, by service.",
+	}, []string{"namespace", "service"})
+
+	// Inventory reports the free and claimed BMHs counted by each SIPInventory. Hosts that no SIPInventory group
+	// selects are not reported.
+	Inventory = NewInventoryCollector()
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ScheduleAttempts,
+		ScheduleFailures,
+		ScheduleDuration,
+		HostsExcluded,
+		ServiceDeployErrors,
+		Inventory,
+	)
+}
+
+// ObserveSchedule records a schedule attempt that started at start and ended with err.
+func ObserveSchedule(start time.Time, err error, reason string) {
+	ScheduleAttempts.Inc()
+	ScheduleDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		ScheduleFailures.WithLabelValues(reason).Inc()
+	}
+}
+
+var (
+	groupHostsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "inventory", "hosts"),
+		"Number of free or claimed BMHs in a SIPInventory group.",
+		[]string{"inventory", "group", "state"}, nil)
+	topologyDomainHostsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "inventory", "topology_domain_hosts"),
+		"Number of free or claimed BMHs in a topology domain of a SIPInventory group.",
+		[]string{"inventory", "group", "topology_key", "topology_domain", "state"}, nil)
+	roleHostsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "inventory", "role_hosts"),
+		"Number of claimed BMHs in a SIPInventory group by the role they were scheduled for.",
+		[]string{"inventory", "group", "role"}, nil)
+)
+
+// InventoryCollector is a Prometheus collector for the host counts of SIPInventories. It reports the counts that were
+// last set for each SIPInventory, so that groups and inventories that are removed are no longer reported.
+type InventoryCollector struct {
+	mu          sync.Mutex
+	inventories map[string][]airshipv1.InventoryGroupStatus
+}
+
+// NewInventoryCollector returns an InventoryCollector that reports no SIPInventories.
+func NewInventoryCollector() *InventoryCollector {
+	return &InventoryCollector{
+		inventories: make(map[string][]airshipv1.InventoryGroupStatus),
+	}
+}
+
+// Set replaces the host counts reported for a SIPInventory.
+func (c *InventoryCollector) Set(inventory string, groups []airshipv1.InventoryGroupStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inventories[inventory] = groups
+}
+
+// Delete stops reporting a SIPInventory.
+func (c *InventoryCollector) Delete(inventory string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inventories, inventory)
+}
+
+// Describe implements prometheus.Collector.
+func (c *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- groupHostsDesc
+	ch <- topologyDomainHostsDesc
+	ch <- roleHostsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for inventory, groups := range c.inventories {
+		for _, group := range groups {
+			collectHostCounts(ch, groupHostsDesc, group.HostCounts, inventory, group.Name)
+			for _, domain := range group.TopologyDomains {
+				collectHostCounts(ch, topologyDomainHostsDesc, domain.HostCounts, inventory, group.Name, domain.Key,
+					domain.Value)
+			}
+
+			roles := make([]string, 0, len(group.Roles))
+			for role := range group.Roles {
+				roles = append(roles, string(role))
+			}
+			sort.Strings(roles)
+			for _, role := range roles {
+				ch <- prometheus.MustNewConstMetric(roleHostsDesc, prometheus.GaugeValue,
+					float64(group.Roles[airshipv1.BMHRole(role)]), inventory, group.Name, role)
+			}
+		}
+	}
+}
+
+func collectHostCounts(ch chan<- prometheus.Metric, desc *prometheus.Desc, counts airshipv1.HostCounts,
+	labelValues ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(counts.Free),
+		append(labelValues, "free")...)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(counts.Claimed),
+		append(labelValues, "claimed")...)
+}
@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	Context("When collecting inventory host counts", func() {
+		var collector *metrics.InventoryCollector
+
+		BeforeEach(func() {
+			collector = metrics.NewInventoryCollector()
+			collector.Set("inventory", []airshipv1.InventoryGroupStatus{
+				{
+					Name:       "worker",
+					HostCounts: airshipv1.HostCounts{Free: 2, Claimed: 1},
+					Roles:      map[airshipv1.BMHRole]int{airshipv1.RoleWorker: 1},
+					TopologyDomains: []airshipv1.TopologyDomainStatus{
+						{Key: "rack", Value: "r8", HostCounts: airshipv1.HostCounts{Free: 2, Claimed: 1}},
+					},
+				},
+			})
+		})
+
+		It("Reports the free and claimed hosts of each group, topology domain and role", func() {
+			expected := `
+# HELP sip_inventory_hosts Number of free or claimed BMHs in a SIPInventory group.
+# TYPE sip_inventory_hosts gauge
+sip_inventory_hosts{group="worker",inventory="inventory",state="claimed"} 1
+sip_inventory_hosts{group="worker",inventory="inventory",state="free"} 2
+# HELP sip_inventory_role_hosts Number of claimed BMHs in a SIPInventory group by the role they were scheduled for.
+# TYPE sip_inventory_role_hosts gauge
+sip_inventory_role_hosts{group="worker",inventory="inventory",role="Worker"} 1
+`
+			Expect(testutil.CollectAndCompare(collector, strings.NewReader(expected),
+				"sip_inventory_hosts", "sip_inventory_role_hosts")).To(Succeed())
+			Expect(testutil.CollectAndCount(collector, "sip_inventory_topology_domain_hosts")).To(Equal(2))
+		})
+
+		It("Stops reporting a deleted inventory", func() {
+			collector.Delete("inventory")
+			Expect(testutil.CollectAndCount(collector)).To(Equal(0))
+		})
+	})
+
+	Context("When observing schedule attempts", func() {
+		It("Counts failures by reason", func() {
+			attempts := testutil.ToFloat64(metrics.ScheduleAttempts)
+			failures := testutil.ToFloat64(
+				metrics.ScheduleFailures.WithLabelValues(metrics.ScheduleFailureInsufficientHosts))
+
+			metrics.ObserveSchedule(time.Now(), nil, metrics.ScheduleFailureError)
+			metrics.ObserveSchedule(time.Now(), errors.New("not enough hosts"),
+				metrics.ScheduleFailureInsufficientHosts)
+
+			Expect(testutil.ToFloat64(metrics.ScheduleAttempts)).To(Equal(attempts + 2))
+			Expect(testutil.ToFloat64(
+				metrics.ScheduleFailures.WithLabelValues(metrics.ScheduleFailureInsufficientHosts))).
+				To(Equal(failures + 1))
+		})
+	})
+})
@@ -0,0 +1,139 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package services
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+// loadBalancerEngine renders the configuration and the container of the proxy that a load balancer runs.
+type loadBalancerEngine interface {
+	// name is the name of the proxy, which labels the pods of the load balancer.
+	name() string
+	// templateKey returns the key of the configuration template of the load balancers of a role in their template
+	// ConfigMap.
+	templateKey(role airshipv1.BMHRole) string
+	// configKey is the key of the rendered configuration in the configuration Secret of the load balancer.
+	configKey() string
+	// defaultImage is the image of the proxy that load balancers run unless they set their image.
+	defaultImage() string
+	// container returns the proxy container, which reads its configuration from the configuration Secret volume.
+	container(image string, ports []corev1.ContainerPort) corev1.Container
+	// metricsConfig returns the configuration that serves the Prometheus metrics of the proxy on port, which is
+	// appended to the rendered configuration, or nil if the proxy exports no metrics.
+	metricsConfig(port int32) []byte
+}
+
+// engines holds the supported load balancer engines.
+var engines = map[airshipv1.LoadBalancerEngine]loadBalancerEngine{
+	airshipv1.EngineHAProxy: haproxyEngine{},
+	airshipv1.EngineNGINX:   nginxEngine{},
+}
+
+// engineKind returns the engine of a load balancer, which is HAProxy unless set.
+func engineKind(config airshipv1.SIPClusterService) airshipv1.LoadBalancerEngine {
+	if _, supported := engines[config.Engine]; !supported {
+		return airshipv1.EngineHAProxy
+	}
+	return config.Engine
+}
+
+// haproxyEngine runs HAProxy with the haproxy.cfg configuration file.
+type haproxyEngine struct{}
+
+func (haproxyEngine) name() string { return "haproxy" }
+
+func (haproxyEngine) templateKey(role airshipv1.BMHRole) string {
+	if role == airshipv1.RoleControlPlane {
+		return "loadBalancerControlPlane.cfg"
+	}
+	return "loadBalancerWorker.cfg"
+}
+
+func (haproxyEngine) configKey() string { return "haproxy.cfg" }
+
+func (haproxyEngine) defaultImage() string { return airshipv1.DefaultLoadBalancerImage }
+
+func (haproxyEngine) container(image string, ports []corev1.ContainerPort) corev1.Container {
+	return corev1.Container{
+		Name:            LoadBalancerServiceName,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Ports:           ports,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      ConfigSecretName,
+				MountPath: "/usr/local/etc/haproxy",
+			},
+		},
+	}
+}
+
+// haproxyMetricsConfig serves the metrics of the built-in Prometheus exporter of HAProxy and its statistics page.
+const haproxyMetricsConfig = `
+frontend sip-metrics
+  bind *:%d
+  mode http
+  option dontlog-normal
+  http-request use-service prometheus-exporter if { path /metrics }
+  stats enable
+  stats uri /stats
+  stats refresh 10s
+`
+
+func (haproxyEngine) metricsConfig(port int32) []byte {
+	return []byte(fmt.Sprintf(haproxyMetricsConfig, port))
+}
+
+// nginxEngine runs the stream proxy of NGINX with the nginx.conf configuration file, which replaces the configuration
+// of the image.
+type nginxEngine struct{}
+
+func (nginxEngine) name() string { return "nginx" }
+
+func (nginxEngine) templateKey(role airshipv1.BMHRole) string {
+	if role == airshipv1.RoleControlPlane {
+		return "loadBalancerControlPlane.nginx.conf"
+	}
+	return "loadBalancerWorker.nginx.conf"
+}
+
+func (nginxEngine) configKey() string { return "nginx.conf" }
+
+func (nginxEngine) defaultImage() string { return airshipv1.DefaultNGINXLoadBalancerImage }
+
+func (e nginxEngine) container(image string, ports []corev1.ContainerPort) corev1.Container {
+	const configPath = "/etc/nginx/sip"
+	return corev1.Container{
+		Name:            LoadBalancerServiceName,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"nginx", "-c", configPath + "/" + e.configKey(), "-g", "daemon off;"},
+		Ports:           ports,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      ConfigSecretName,
+				MountPath: configPath,
+			},
+		},
+	}
+}
+
+// The stream proxy of NGINX has no statistics to export.
+func (nginxEngine) metricsConfig(int32) []byte { return nil }
@@ -16,6 +16,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ErrInvalidAuthorizedKeyFormat occurs when an authorized key in the SIP CR does not meet the expected format.
@@ -36,3 +37,25 @@ type ErrMalformedRedfishAddress struct {
 func (e ErrMalformedRedfishAddress) Error() string {
 	return fmt.Sprintf("invalid Redfish BMC address %s", e.Address)
 }
+
+// ErrObjectRetained occurs when an object that a service generates exists already, and was retained on deletion of
+// an earlier SIPCluster. Retained objects have to be removed by hand.
+type ErrObjectRetained struct {
+	Object     string
+	SIPCluster string
+}
+
+func (e ErrObjectRetained) Error() string {
+	return fmt.Sprintf("%s was retained on deletion of SIPCluster %s and must be removed first", e.Object,
+		e.SIPCluster)
+}
+
+// ErrNodePortConflict occurs when node ports of a SIPCluster are in use by other SIPClusters or Services, or when no
+// free node ports are left to allocate.
+type ErrNodePortConflict struct {
+	Conflicts []string
+}
+
+func (e ErrNodePortConflict) Error() string {
+	return fmt.Sprintf("unable to allocate node ports: %s", strings.Join(e.Conflicts, "; "))
+}
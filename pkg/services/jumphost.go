@@ -16,17 +16,19 @@ package services
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"golang.org/x/crypto/ssh"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -60,30 +62,48 @@ const (
 
 // JumpHost is an InfrastructureService that provides SSH and power-management capabilities for sub-clusters.
 type jumpHost struct {
-	client   client.Client
-	sipName  types.NamespacedName
-	logger   logr.Logger
-	config   airshipv1.JumpHostService
-	machines *bmh.MachineList
+	client             client.Client
+	sipName            types.NamespacedName
+	index              int
+	owner              *metav1.OwnerReference
+	logger             logr.Logger
+	config             airshipv1.JumpHostService
+	machines           *bmh.MachineList
+	nodeSSHPrivateKeys *corev1.Secret
 }
 
-func newJumpHost(name, namespace string, logger logr.Logger, config airshipv1.JumpHostService,
-	machines *bmh.MachineList, client client.Client) InfraService {
+func newJumpHost(name, namespace string, index int, owner *metav1.OwnerReference, logger logr.Logger,
+	config airshipv1.JumpHostService, machines *bmh.MachineList, nodeSSHPrivateKeys *corev1.Secret,
+	client client.Client) InfraService {
 	return jumpHost{
 		sipName: types.NamespacedName{
 			Name:      name,
 			Namespace: namespace,
 		},
-		logger:   logger,
-		config:   config,
-		machines: machines,
-		client:   client,
+		index:              index,
+		owner:              owner,
+		logger:             logger,
+		config:             config,
+		machines:           machines,
+		nodeSSHPrivateKeys: nodeSSHPrivateKeys,
+		client:             client,
 	}
 }
 
 // Deploy creates a JumpHost service in the base cluster.
 func (jh jumpHost) Deploy() error {
-	instance := JumpHostServiceName + "-" + jh.sipName.Name
+	objs, err := jh.Render()
+	if err != nil {
+		return err
+	}
+
+	// Readiness is reported by ServiceSet.Status
+	return applyObjects(objs, jh.client, jh.logger)
+}
+
+// Render returns the Service, Secret, ConfigMap and Deployment of the JumpHost, in the order they are applied.
+func (jh jumpHost) Render() ([]client.Object, error) {
+	instance := jh.Name()
 	labels := map[string]string{
 		// See https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/#labels
 		"app.kubernetes.io/part-of":   "sip",
@@ -94,48 +114,38 @@ func (jh jumpHost) Deploy() error {
 
 	hostAliases := jh.generateHostAliases()
 
-	// TODO: Validate Service becomes ready.
 	service := jh.generateService(instance, labels)
-	jh.logger.Info("Applying service", "service", service.GetNamespace()+"/"+service.GetName())
-	err := applyRuntimeObject(client.ObjectKey{Name: service.GetName(), Namespace: service.GetNamespace()},
-		service, jh.client)
-	if err != nil {
-		return err
-	}
 
 	secret, err := jh.generateSecret(instance, labels, hostAliases)
 	if err != nil {
-		return err
-	}
-
-	jh.logger.Info("Applying secret", "secret", secret.GetNamespace()+"/"+secret.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: secret.GetName(), Namespace: secret.GetNamespace()},
-		secret, jh.client)
-	if err != nil {
-		return err
+		return nil, err
 	}
 
 	configMap, err := jh.generateConfigMap(instance, labels)
 	if err != nil {
-		return err
-	}
-	jh.logger.Info("Applying configmap", "configmap", configMap.GetNamespace()+"/"+configMap.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: configMap.GetName(), Namespace: configMap.GetNamespace()},
-		configMap, jh.client)
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// TODO: Validate Deployment becomes ready.
 	deployment := jh.generateDeployment(instance, labels, hostAliases)
-	jh.logger.Info("Applying deployment", "deployment", deployment.GetNamespace()+"/"+deployment.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: deployment.GetName(), Namespace: deployment.GetNamespace()},
-		deployment, jh.client)
-	if err != nil {
-		return err
-	}
 
-	return nil
+	objs := []client.Object{service, secret, configMap, deployment}
+	setOwnership(objs, jh.sipName.Name, instance, jh.owner)
+	return objs, nil
+}
+
+// Name returns the name of the JumpHost instance. The first jump host is named jumphost-<SIPCluster> and the others
+// after their index, e.g. jumphost1-<SIPCluster>, so that every jump host renders its own objects.
+func (jh jumpHost) Name() string {
+	return jumpHostName(jh.sipName.Name, jh.index)
+}
+
+// jumpHostName returns the name of the jump host at index in the spec of the SIPCluster sipName.
+func jumpHostName(sipName string, index int) string {
+	name := JumpHostServiceName
+	if index > 0 {
+		name += strconv.Itoa(index)
+	}
+	return name + "-" + sipName
 }
 
 func (jh jumpHost) generateDeployment(instance string, labels map[string]string,
@@ -273,7 +283,10 @@ func (jh jumpHost) generateDeployment(instance string, labels map[string]string,
 							},
 						},
 					},
-					HostAliases: hostAliases,
+					HostAliases:  hostAliases,
+					NodeSelector: jh.config.NodeLabels,
+					Affinity:     jh.config.Affinity,
+					Tolerations:  jh.config.Tolerations,
 				},
 			},
 		},
@@ -360,19 +373,15 @@ func (jh jumpHost) generateSecret(instance string, labels map[string]string, hos
 }
 
 func (jh jumpHost) generateSSHConfig(hostAliases []corev1.HostAlias) ([]byte, error) {
-	key := types.NamespacedName{
-		Namespace: jh.sipName.Namespace,
-		Name:      jh.config.NodeSSHPrivateKeys,
-	}
-	secret := &corev1.Secret{}
-	if err := jh.client.Get(context.Background(), key, secret); err != nil {
-		return nil, err
+	if jh.nodeSSHPrivateKeys == nil {
+		return nil, apierror.NewNotFound(corev1.Resource("secrets"), jh.config.NodeSSHPrivateKeys)
 	}
 
 	identityFiles := []string{}
-	for k := range secret.Data {
+	for k := range jh.nodeSSHPrivateKeys.Data {
 		identityFiles = append(identityFiles, mountPathNodeSSHPrivateKeys+"/"+k)
 	}
+	sort.Strings(identityFiles)
 	hostNames := []string{}
 	for _, hostAlias := range hostAliases {
 		hostNames = append(hostNames, hostAlias.Hostnames[0])
@@ -430,6 +439,8 @@ func (jh jumpHost) generateHostAliases() []corev1.HostAlias {
 		hostname := machine.BMH.Name
 		hostAliases = append(hostAliases, corev1.HostAlias{IP: ip, Hostnames: []string{hostname}})
 	}
+	// Machines are kept in a map; sort them so that rendering is reproducible
+	sort.Slice(hostAliases, func(i, j int) bool { return hostAliases[i].Hostnames[0] < hostAliases[j].Hostnames[0] })
 	return hostAliases
 }
 
@@ -439,24 +450,25 @@ func (jh jumpHost) generateService(instance string, labels map[string]string) *c
 			Name:      instance,
 			Namespace: jh.sipName.Namespace,
 		},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				{
-					Name:     "ssh",
-					Port:     22,
-					NodePort: int32(jh.config.NodePort),
-				},
+		Spec: serviceSpec(jh.config.SIPClusterService, []corev1.ServicePort{
+			{
+				Name:     "ssh",
+				Port:     22,
+				NodePort: int32(jh.config.NodePort),
 			},
-			Selector: labels,
-			Type:     corev1.ServiceTypeNodePort,
-		},
+		}, labels),
 	}
 }
 
-// Finalize removes a deployed JumpHost service.
+// Finalize removes the Deployment, ConfigMap, Secret and Service of a deployed JumpHost service.
 func (jh jumpHost) Finalize() error {
-	// TODO(drewwalters96): Add logic to cleanup SIPCluster JumpHost pod.
-	return nil
+	meta := metav1.ObjectMeta{Name: jh.Name(), Namespace: jh.sipName.Namespace}
+	return deleteObjects([]client.Object{
+		&appsv1.Deployment{ObjectMeta: meta},
+		&corev1.ConfigMap{ObjectMeta: meta},
+		&corev1.Secret{ObjectMeta: meta},
+		&corev1.Service{ObjectMeta: meta},
+	}, jh.client, jh.logger)
 }
 
 type host struct {
@@ -491,6 +503,7 @@ func generateHostList(machineList bmh.MachineList) ([]byte, error) {
 
 		hosts = append(hosts, h)
 	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
 
 	out, err := json.Marshal(hosts)
 	if err != nil {
@@ -0,0 +1,172 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	keepalivedConfigKey  = "keepalived.conf"
+	keepalivedConfigPath = "/etc/keepalived"
+)
+
+// keepalivedTemplate configures every keepalived instance as a backup with the same priority, so that the instance
+// with the highest address holds the virtual IP, and it is not taken back from a newer master.
+var keepalivedTemplate = template.Must(template.New("keepalived-config").Parse(`vrrp_instance {{ .Name }} {
+    state BACKUP
+    interface {{ .Interface }}
+    virtual_router_id {{ .VirtualRouterID }}
+    priority 100
+    advert_int 1
+    nopreempt
+    virtual_ipaddress {
+        {{ .VirtualIP }}
+    }
+}
+`))
+
+// keepalivedName returns the name of the keepalived objects that announce the virtual IP of the load balancer.
+func (lb loadBalancer) keepalivedName() string {
+	return lb.Name() + "-keepalived"
+}
+
+// generateKeepalived returns the configuration ConfigMap, Deployment and PodDisruptionBudget of the keepalived
+// instances that announce the virtual IP of the load balancer. They run on the network of the nodes, and are placed
+// like the load balancer.
+func (lb loadBalancer) generateKeepalived() ([]client.Object, error) {
+	name := lb.keepalivedName()
+	labels := map[string]string{
+		"app.kubernetes.io/part-of":   "sip",
+		"app.kubernetes.io/component": LoadBalancerServiceName,
+		"app.kubernetes.io/name":      "keepalived",
+		"app.kubernetes.io/instance":  name,
+	}
+
+	config := bytes.NewBuffer([]byte{})
+	err := keepalivedTemplate.Execute(config, map[string]interface{}{
+		"Name":            lb.sipName.Name,
+		"Interface":       lb.virtualIP.Interface,
+		"VirtualRouterID": lb.virtualIP.VirtualRouterID,
+		"VirtualIP":       *lb.config.ClusterIP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: lb.sipName.Namespace,
+		},
+		Data: map[string]string{
+			keepalivedConfigKey: config.String(),
+		},
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: lb.sipName.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32Ptr(lb.replicas),
+			Strategy: rolloutStrategy(lb.replicas),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					Annotations: map[string]string{
+						ConfigHashAnnotation: configHash(map[string][]byte{keepalivedConfigKey: config.Bytes()}),
+					},
+				},
+				Spec: corev1.PodSpec{
+					HostNetwork: true,
+					Containers: []corev1.Container{
+						{
+							Name:            "keepalived",
+							Image:           lb.virtualIP.Image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command: []string{"keepalived", "--dont-fork", "--log-console",
+								"--use-file=" + keepalivedConfigPath + "/" + keepalivedConfigKey},
+							SecurityContext: &corev1.SecurityContext{
+								Capabilities: &corev1.Capabilities{
+									Add: []corev1.Capability{"NET_ADMIN", "NET_BROADCAST", "NET_RAW"},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "keepalived-config",
+									MountPath: keepalivedConfigPath,
+								},
+							},
+						},
+					},
+					NodeSelector: lb.config.NodeLabels,
+					Affinity:     spreadPods(lb.config.Affinity, labels, lb.replicas),
+					Tolerations:  lb.config.Tolerations,
+					Volumes: []corev1.Volume{
+						{
+							Name: "keepalived-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: name,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return []client.Object{configMap, deployment, generateDisruptionBudget(name, lb.sipName.Namespace, labels)}, nil
+}
+
+// keepalivedObjects returns the keepalived objects of the load balancer, for deletion.
+func (lb loadBalancer) keepalivedObjects() []client.Object {
+	meta := metav1.ObjectMeta{Name: lb.keepalivedName(), Namespace: lb.sipName.Namespace}
+	return []client.Object{
+		&appsv1.Deployment{ObjectMeta: meta},
+		&corev1.ConfigMap{ObjectMeta: meta},
+		&policyv1beta1.PodDisruptionBudget{ObjectMeta: meta},
+	}
+}
+
+// removeKeepalived deletes the keepalived objects of a load balancer that no longer announces a virtual IP.
+func (lb loadBalancer) removeKeepalived() error {
+	key := client.ObjectKey{Name: lb.keepalivedName(), Namespace: lb.sipName.Namespace}
+	err := lb.client.Get(context.Background(), key, &appsv1.Deployment{})
+	switch {
+	case apierror.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	return deleteObjects(lb.keepalivedObjects(), lb.client, lb.logger)
+}
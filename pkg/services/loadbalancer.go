@@ -16,8 +16,11 @@ package services
 
 import (
 	"bytes"
-	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"html/template"
@@ -29,61 +32,96 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	// ConfigSecretName name of the haproxy config secret name/volume/mount
+	// ConfigSecretName is the name of the volume that load balancers mount their configuration Secret from. It keeps
+	// its HAProxy name for every engine, so that the Deployments of existing load balancers are not changed.
 	/* #nosec */
-	ConfigSecretName = "haproxy-config"
-	// DefaultBalancerImage is the image that will be used as load balancer
-	DefaultBalancerImage    = "haproxy:2.3.2"
+	ConfigSecretName        = "haproxy-config"
 	LoadBalancerServiceName = "loadbalancer"
+
+	// ControlPlaneTemplateConfigMapName is the ConfigMap that holds the control plane load balancer template, in the
+	// namespace of the SIPCluster
+	ControlPlaneTemplateConfigMapName = "loadbalancercontrolplane"
+	// WorkerTemplateConfigMapName is the ConfigMap that holds the worker load balancer template, in the namespace of
+	// the SIPCluster
+	WorkerTemplateConfigMapName = "loadbalancerworker"
+
+	// ConfigHashAnnotation annotates the pod template of a load balancer with a hash of its configuration, so that
+	// its pods are replaced when the configuration changes, e.g. when nodes are added to or removed from the cluster.
+	ConfigHashAnnotation = bmh.BaseAirshipSelector + "/config-hash"
 )
 
 func (lb loadBalancer) Deploy() error {
-	if lb.config.Image == "" {
-		lb.config.Image = DefaultBalancerImage
+	objs, err := lb.Render()
+	if err != nil {
+		return err
 	}
 
-	instance := LoadBalancerServiceName + "-" + strings.ToLower(string(lb.bmhRole)) + "-" + lb.sipName.Name
+	// Readiness is reported by ServiceSet.Status
+	if err = applyObjects(objs, lb.client, lb.logger); err != nil {
+		return err
+	}
+	if err = lb.removeMetrics(); err != nil {
+		return err
+	}
+	if lb.virtualIP == nil {
+		return lb.removeKeepalived()
+	}
+	return nil
+}
+
+// Render returns the configuration Secret, Deployment, Service and PodDisruptionBudget of the load balancer, followed
+// by its metrics objects and the keepalived objects that announce its virtual IP, in the order they are applied.
+func (lb loadBalancer) Render() ([]client.Object, error) {
+	instance := lb.Name()
 	labels := map[string]string{
 		// See https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/#labels
 		"app.kubernetes.io/part-of":   "sip",
 		"app.kubernetes.io/component": LoadBalancerServiceName,
-		"app.kubernetes.io/name":      "haproxy",
+		"app.kubernetes.io/name":      lb.engine.name(),
 		"app.kubernetes.io/instance":  instance,
 	}
 
 	deployment, secret, err := lb.generateDeploymentAndSecret(instance, labels)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	lb.logger.Info("Applying loadbalancer secret", "secret", secret.GetNamespace()+"/"+secret.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: secret.GetName(), Namespace: secret.GetNamespace()}, secret, lb.client)
-	if err != nil {
-		return err
+	objs := []client.Object{secret, deployment, lb.generateService(instance, labels),
+		generateDisruptionBudget(instance, lb.sipName.Namespace, labels)}
+	if lb.metricsPort() != 0 {
+		objs = append(objs, lb.generateMetrics(labels)...)
 	}
-
-	// TODO: Validate Deployment becomes ready.
-	lb.logger.Info("Applying loadbalancer deployment", "deployment", deployment.GetNamespace()+"/"+deployment.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: deployment.GetName(), Namespace: deployment.GetNamespace()},
-		deployment, lb.client)
-	if err != nil {
-		return err
+	if lb.virtualIP != nil {
+		keepalived, err := lb.generateKeepalived()
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, keepalived...)
 	}
+	setOwnership(objs, lb.sipName.Name, instance, lb.owner)
+	return objs, nil
+}
 
-	// TODO: Validate Service becomes ready.
-	lbService := lb.generateService(instance, labels)
-	lb.logger.Info("Applying loadbalancer service", "service", lbService.GetNamespace()+"/"+lbService.GetName())
-	err = applyRuntimeObject(client.ObjectKey{Name: lbService.GetName(), Namespace: lbService.GetNamespace()},
-		lbService, lb.client)
-	if err != nil {
-		return err
+// Name returns the name of the load balancer instance. The first load balancer of a role is named after the role,
+// and the others after the role and their index, e.g. loadbalancer-controlplane1-<SIPCluster>, so that the names of
+// the load balancers of the SIPClusters in a namespace are unique.
+func (lb loadBalancer) Name() string {
+	return loadBalancerName(lb.bmhRole, lb.sipName.Name, lb.index)
+}
+
+// loadBalancerName returns the name of the load balancer of role at index in the spec of the SIPCluster sipName.
+func loadBalancerName(role airshipv1.BMHRole, sipName string, index int) string {
+	name := strings.ToLower(string(role))
+	if index > 0 {
+		name += strconv.Itoa(index)
 	}
-	return nil
+	return LoadBalancerServiceName + "-" + name + "-" + sipName
 }
 
 func (lb loadBalancer) generateDeploymentAndSecret(instance string, labels map[string]string) (*appsv1.Deployment,
@@ -92,6 +130,10 @@ func (lb loadBalancer) generateDeploymentAndSecret(instance string, labels map[s
 	if err != nil {
 		return nil, nil, err
 	}
+	ports := lb.getContainerPorts()
+	if port := lb.metricsPort(); port != 0 {
+		ports = append(ports, corev1.ContainerPort{Name: metricsPortName, ContainerPort: port})
+	}
 
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -100,38 +142,25 @@ func (lb loadBalancer) generateDeploymentAndSecret(instance string, labels map[s
 			Labels:    labels,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: int32Ptr(1),
+			Replicas: int32Ptr(lb.replicas),
+			Strategy: rolloutStrategy(lb.replicas),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels,
+					Annotations: map[string]string{
+						ConfigHashAnnotation: configHash(secret.Data),
+					},
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
-						{
-							Name:            LoadBalancerServiceName,
-							Image:           lb.config.Image,
-							ImagePullPolicy: corev1.PullIfNotPresent,
-							Ports:           lb.getContainerPorts(),
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      ConfigSecretName,
-									MountPath: "/usr/local/etc/haproxy",
-								},
-							},
-						},
-					},
-					NodeSelector: map[string]string{
-						"node-role.kubernetes.io/master": "",
-					},
-					Tolerations: []corev1.Toleration{
-						{
-							Key:    "node-role.kubernetes.io/master",
-							Effect: "NoSchedule",
-						},
+						lb.engine.container(lb.image(), ports),
 					},
+					NodeSelector: lb.config.NodeLabels,
+					Affinity:     spreadPods(lb.config.Affinity, labels, lb.replicas),
+					Tolerations:  lb.config.Tolerations,
 					Volumes: []corev1.Volume{
 						{
 							Name: ConfigSecretName,
@@ -150,12 +179,20 @@ func (lb loadBalancer) generateDeploymentAndSecret(instance string, labels map[s
 	return deployment, secret, nil
 }
 
+// image returns the image of the load balancer, which is the image of its engine unless it sets one.
+func (lb loadBalancer) image() string {
+	if lb.config.Image != "" {
+		return lb.config.Image
+	}
+	return lb.engine.defaultImage()
+}
+
 func (lb loadBalancer) getContainerPorts() []corev1.ContainerPort {
 	containerPorts := []corev1.ContainerPort{}
-	for _, servicePort := range lb.servicePorts {
+	for _, port := range lb.ports {
 		containerPorts = append(containerPorts, corev1.ContainerPort{
-			Name:          servicePort.Name,
-			ContainerPort: servicePort.Port,
+			Name:          port.Name,
+			ContainerPort: int32(port.FrontendPort),
 		})
 	}
 	return containerPorts
@@ -164,6 +201,7 @@ func (lb loadBalancer) getContainerPorts() []corev1.ContainerPort {
 func (lb loadBalancer) generateSecret(instance string) (*corev1.Secret, error) {
 	p := proxy{
 		ContainerPorts: lb.getContainerPorts(),
+		Ports:          lb.ports,
 		Servers:        make([]server, 0),
 	}
 	for _, machine := range lb.machines.Machines {
@@ -181,10 +219,15 @@ func (lb loadBalancer) generateSecret(instance string) (*corev1.Secret, error) {
 			p.Servers = append(p.Servers, server{IP: ip, Name: machine.BMH.Name})
 		}
 	}
+	// Machines are kept in a map; sort them so that rendering is reproducible
+	sort.Slice(p.Servers, func(i, j int) bool { return p.Servers[i].Name < p.Servers[j].Name })
 	secretData, err := lb.generateTemplate(p)
 	if err != nil {
 		return nil, err
 	}
+	if port := lb.metricsPort(); port != 0 {
+		secretData = append(secretData, lb.engine.metricsConfig(port)...)
+	}
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instance,
@@ -192,27 +235,122 @@ func (lb loadBalancer) generateSecret(instance string) (*corev1.Secret, error) {
 		},
 		Type: corev1.SecretTypeOpaque,
 		Data: map[string][]byte{
-			"haproxy.cfg": secretData,
+			lb.engine.configKey(): secretData,
 		},
 	}, nil
 }
 
 func (lb loadBalancer) generateService(instance string, labels map[string]string) *corev1.Service {
+	config := lb.config
+	if lb.virtualIP != nil {
+		// The virtual IP is announced by keepalived on the nodes, which forward it to the Service
+		config.ExternalIPs = append(append([]string{}, config.ExternalIPs...), *config.ClusterIP)
+		config.ClusterIP = nil
+	}
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instance,
 			Namespace: lb.sipName.Namespace,
 		},
-		Spec: corev1.ServiceSpec{
-			Ports:    lb.servicePorts,
-			Selector: labels,
-			Type:     corev1.ServiceTypeNodePort,
+		Spec: serviceSpec(config, lb.servicePorts(), labels),
+	}
+}
+
+// servicePorts returns the ports of the load balancer Service, which exposes the frontend ports of the load balancer.
+func (lb loadBalancer) servicePorts() []corev1.ServicePort {
+	servicePorts := []corev1.ServicePort{}
+	for _, port := range lb.ports {
+		servicePorts = append(servicePorts, corev1.ServicePort{
+			Name:     port.Name,
+			Port:     int32(port.FrontendPort),
+			NodePort: int32(port.NodePort),
+		})
+	}
+	return servicePorts
+}
+
+// configHash returns a hash of the data of a configuration Secret or ConfigMap.
+func configHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		// Lengths keep the boundaries between keys and values unambiguous
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(data[key]))
+		hash.Write(data[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// generateDisruptionBudget returns a PodDisruptionBudget that lets one of the pods selected by labels be disrupted at
+// a time.
+func generateDisruptionBudget(name, namespace string, labels map[string]string) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}
+}
+
+// spreadPods returns the affinity of the pods selected by labels. Several replicas have a required pod anti-affinity
+// on the hostname in addition to the affinity that is set for the service, so that no two of them run on the same
+// node.
+func spreadPods(affinity *corev1.Affinity, labels map[string]string, replicas int32) *corev1.Affinity {
+	if replicas < 2 {
+		return affinity
+	}
+
+	spread := &corev1.Affinity{}
+	if affinity != nil {
+		spread = affinity.DeepCopy()
+	}
+	if spread.PodAntiAffinity == nil {
+		spread.PodAntiAffinity = &corev1.PodAntiAffinity{}
+	}
+	spread.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+		spread.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+			TopologyKey:   corev1.LabelHostname,
+		})
+	return spread
+}
+
+// rolloutStrategy returns the strategy of a Deployment of spread pods. Several replicas are replaced one at a time
+// without a surge pod, which the anti-affinity of spreadPods would keep from being scheduled when every node already
+// runs a replica.
+func rolloutStrategy(replicas int32) appsv1.DeploymentStrategy {
+	if replicas < 2 {
+		return appsv1.DeploymentStrategy{}
+	}
+
+	maxSurge := intstr.FromInt(0)
+	maxUnavailable := intstr.FromInt(1)
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
 		},
 	}
 }
 
+// proxy holds the data of the load balancer configuration templates. Templates render a frontend and a backend for
+// each of the Ports, which have the same names and frontend ports as the ContainerPorts.
 type proxy struct {
 	ContainerPorts []corev1.ContainerPort
+	Ports          []airshipv1.LoadBalancerPort
 	Servers        []server
 }
 
@@ -222,14 +360,19 @@ type server struct {
 }
 
 type loadBalancer struct {
-	client       client.Client
-	sipName      types.NamespacedName
-	logger       logr.Logger
-	config       airshipv1.SIPClusterService
-	machines     *bmh.MachineList
-	bmhRole      airshipv1.BMHRole
-	template     string
-	servicePorts []corev1.ServicePort
+	client    client.Client
+	sipName   types.NamespacedName
+	index     int
+	owner     *metav1.OwnerReference
+	logger    logr.Logger
+	config    airshipv1.SIPClusterService
+	machines  *bmh.MachineList
+	bmhRole   airshipv1.BMHRole
+	engine    loadBalancerEngine
+	template  string
+	ports     []airshipv1.LoadBalancerPort
+	replicas  int32
+	virtualIP *airshipv1.VirtualIPOpts
 }
 
 type loadBalancerControlPlane struct {
@@ -242,95 +385,93 @@ type loadBalancerWorker struct {
 	config airshipv1.LoadBalancerServiceWorker
 }
 
-func newLBControlPlane(name, namespace string,
+func newLBControlPlane(name, namespace string, index int,
+	owner *metav1.OwnerReference,
 	logger logr.Logger,
 	config airshipv1.LoadBalancerServiceControlPlane,
 	machines *bmh.MachineList,
+	templates map[airshipv1.LoadBalancerEngine]string,
 	mgrClient client.Client) loadBalancerControlPlane {
-	servicePorts := []corev1.ServicePort{
-		{
-			Name:     "http",
-			Port:     6443,
-			NodePort: int32(config.NodePort),
-		},
+	replicas := int32(config.Replicas)
+	if replicas == 0 {
+		replicas = 1
 	}
-	//Get template string from the secret
-	templateControlPlane := ""
-	cm := &corev1.ConfigMap{}
-	err := mgrClient.Get(context.Background(), client.ObjectKey{
-		Name:      "loadbalancercontrolplane",
-		Namespace: namespace}, cm)
-	if err != nil {
-		logger.Error(err, "unable to retrieve template info from secret.")
-	}
-	templateControlPlane = cm.Data["loadBalancerControlPlane.cfg"]
 
 	return loadBalancerControlPlane{loadBalancer{
 		sipName: types.NamespacedName{
 			Name:      name,
 			Namespace: namespace,
 		},
-		logger:       logger,
-		config:       config.SIPClusterService,
-		machines:     machines,
-		client:       mgrClient,
-		bmhRole:      airshipv1.RoleControlPlane,
-		template:     templateControlPlane,
-		servicePorts: servicePorts,
+		index:     index,
+		owner:     owner,
+		logger:    logger,
+		config:    config.SIPClusterService,
+		machines:  machines,
+		client:    mgrClient,
+		bmhRole:   airshipv1.RoleControlPlane,
+		engine:    engines[engineKind(config.SIPClusterService)],
+		template:  templates[engineKind(config.SIPClusterService)],
+		ports:     config.ForwardedPorts(),
+		replicas:  replicas,
+		virtualIP: config.VirtualIP,
 	},
 		config,
 	}
 }
 
-func newLBWorker(name, namespace string,
+func newLBWorker(name, namespace string, index int,
+	owner *metav1.OwnerReference,
 	logger logr.Logger,
 	config airshipv1.LoadBalancerServiceWorker,
 	machines *bmh.MachineList,
+	templates map[airshipv1.LoadBalancerEngine]string,
 	mgrClient client.Client) loadBalancerWorker {
-	servicePorts := []corev1.ServicePort{}
+	ports := []airshipv1.LoadBalancerPort{}
 	for port := config.NodePortRange.Start; port <= config.NodePortRange.End; port++ {
-		servicePorts = append(servicePorts, corev1.ServicePort{
-			Name:     fmt.Sprintf("port-%d", port),
-			Port:     int32(port),
-			NodePort: int32(port),
+		ports = append(ports, airshipv1.LoadBalancerPort{
+			Name:         fmt.Sprintf("port-%d", port),
+			FrontendPort: port,
+			BackendPort:  port,
+			NodePort:     port,
+			HealthCheck:  airshipv1.HealthCheckTCP,
 		})
 	}
 
-	//Get Template as a secret
-	templateWorker := ""
-	cm := &corev1.ConfigMap{}
-	err := mgrClient.Get(context.Background(), client.ObjectKey{
-		Name:      "loadbalancerworker",
-		Namespace: namespace}, cm)
-	if err != nil {
-		logger.Error(err, "unable to retrieve template info from secret.")
-	}
-	templateWorker = cm.Data["loadBalancerWorker.cfg"]
-
 	return loadBalancerWorker{loadBalancer{
 		sipName: types.NamespacedName{
 			Name:      name,
 			Namespace: namespace,
 		},
-		logger:       logger,
-		config:       config.SIPClusterService,
-		machines:     machines,
-		client:       mgrClient,
-		bmhRole:      airshipv1.RoleWorker,
-		template:     templateWorker,
-		servicePorts: servicePorts,
+		index:    index,
+		owner:    owner,
+		logger:   logger,
+		config:   config.SIPClusterService,
+		machines: machines,
+		client:   mgrClient,
+		bmhRole:  airshipv1.RoleWorker,
+		engine:   engines[engineKind(config.SIPClusterService)],
+		template: templates[engineKind(config.SIPClusterService)],
+		ports:    ports,
+		replicas: 1,
 	},
 		config,
 	}
 }
 
+// Finalize removes the Service, Deployment, configuration Secret, PodDisruptionBudget, metrics and keepalived objects
+// of the load balancer.
 func (lb loadBalancer) Finalize() error {
-	// implete to delete loadbalancer
-	return nil
+	meta := metav1.ObjectMeta{Name: lb.Name(), Namespace: lb.sipName.Namespace}
+	return deleteObjects(append([]client.Object{
+		&corev1.Service{ObjectMeta: meta},
+		&appsv1.Deployment{ObjectMeta: meta},
+		&corev1.Secret{ObjectMeta: meta},
+		&policyv1beta1.PodDisruptionBudget{ObjectMeta: meta},
+	}, append(lb.metricsObjects(), lb.keepalivedObjects()...)...), lb.client, lb.logger)
 }
 
 func (lb loadBalancer) generateTemplate(p proxy) ([]byte, error) {
-	tmpl, err := template.New("haproxy-config").Parse(lb.template)
+	tmpl, err := template.New("loadbalancer-config").Parse(lb.template)
 	if err != nil {
 		return nil, err
 	}
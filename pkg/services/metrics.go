@@ -0,0 +1,141 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+)
+
+const metricsPortName = "metrics"
+
+// ServiceMonitorGVK is the kind of the Prometheus Operator ServiceMonitors of load balancers. ServiceMonitors are
+// handled as unstructured objects, as their CRD is not necessarily installed in the cluster that SIP runs in.
+var ServiceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// metricsName returns the name of the metrics Service and ServiceMonitor of the load balancer.
+func (lb loadBalancer) metricsName() string {
+	return lb.Name() + "-" + metricsPortName
+}
+
+// metricsPort returns the port that the load balancer pods serve their metrics on, or 0 if they export no metrics.
+func (lb loadBalancer) metricsPort() int32 {
+	switch {
+	case lb.config.Metrics == nil:
+		return 0
+	case lb.config.Metrics.Port == 0:
+		return airshipv1.DefaultMetricsPort
+	}
+	return int32(lb.config.Metrics.Port)
+}
+
+// generateMetrics returns the ClusterIP Service that exposes the metrics of the load balancer pods selected by labels,
+// followed by its ServiceMonitor if one is configured.
+func (lb loadBalancer) generateMetrics(labels map[string]string) []client.Object {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      lb.metricsName(),
+			Namespace: lb.sipName.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: labels,
+			Ports: []corev1.ServicePort{{
+				Name:       metricsPortName,
+				Port:       lb.metricsPort(),
+				TargetPort: intstr.FromString(metricsPortName),
+			}},
+		},
+	}
+	objs := []client.Object{service}
+
+	options := lb.config.Metrics.ServiceMonitor
+	if options == nil {
+		return objs
+	}
+	endpoint := map[string]interface{}{"port": metricsPortName, "path": "/metrics"}
+	if options.Interval != "" {
+		endpoint["interval"] = options.Interval
+	}
+	// The main Service of the load balancer is not labeled with the labels of its pods
+	matchLabels := map[string]interface{}{}
+	for k, v := range labels {
+		matchLabels[k] = v
+	}
+	monitor := newServiceMonitor(lb.metricsName(), lb.sipName.Namespace)
+	monitor.SetLabels(options.Labels)
+	monitor.Object["spec"] = map[string]interface{}{
+		"selector":  map[string]interface{}{"matchLabels": matchLabels},
+		"endpoints": []interface{}{endpoint},
+	}
+	return append(objs, monitor)
+}
+
+func newServiceMonitor(name, namespace string) *unstructured.Unstructured {
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(ServiceMonitorGVK)
+	monitor.SetName(name)
+	monitor.SetNamespace(namespace)
+	return monitor
+}
+
+// metricsObjects returns the metrics objects of the load balancer, for deletion.
+func (lb loadBalancer) metricsObjects() []client.Object {
+	return []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: lb.metricsName(), Namespace: lb.sipName.Namespace}},
+		newServiceMonitor(lb.metricsName(), lb.sipName.Namespace),
+	}
+}
+
+// removeMetrics deletes the metrics objects that the load balancer no longer exposes its metrics with: all of them
+// when it exports no metrics, or its ServiceMonitor when none is configured.
+func (lb loadBalancer) removeMetrics() error {
+	objs := lb.metricsObjects()
+	switch {
+	case lb.config.Metrics == nil:
+	case lb.config.Metrics.ServiceMonitor == nil:
+		objs = objs[1:]
+	default:
+		return nil
+	}
+
+	stale := []client.Object{}
+	for _, obj := range objs {
+		existing, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			continue
+		}
+		err := lb.client.Get(context.Background(), client.ObjectKeyFromObject(obj), existing)
+		switch {
+		case apierror.IsNotFound(err) || apimeta.IsNoMatchError(err):
+			continue
+		case err != nil:
+			return err
+		}
+		stale = append(stale, obj)
+	}
+	return deleteObjects(stale, lb.client, lb.logger)
+}
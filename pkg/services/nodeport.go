@@ -0,0 +1,256 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	bmh "sipcluster/pkg/bmh"
+)
+
+// nodePortRequest is the node port, or range of node ports, of an infrastructure service of a SIPCluster. Requests
+// with an empty range are allocated.
+type nodePortRequest struct {
+	// service names the service by the name of its objects, and the ports of control plane load balancers by their
+	// name too, so that the node ports that are allocated to them stay with them when other entries of the spec are
+	// removed or reordered.
+	service string
+	// legacyService names the service by its field and index in the spec, which the node ports that were allocated
+	// by earlier versions of SIP are recorded under.
+	legacyService string
+	size          int
+	ports         airshipv1.PortRange
+	// set writes the node ports of the request into the spec of the SIPCluster.
+	set func(airshipv1.PortRange)
+}
+
+// nodePorts maps the node ports that are in use to a description of their owner.
+type nodePorts map[int]string
+
+// AllocateNodePorts resolves the node ports of the infrastructure services of a SIPCluster. Node ports that are set in
+// the spec are checked against the node ports of the other SIPClusters and of the Services in the cluster, and node
+// ports that are omitted are allocated from the free node ports, keeping the node ports recorded in the status for as
+// long as they are free. The node ports are set in the spec of sip, which is not persisted, and recorded in its status.
+func AllocateNodePorts(ctx context.Context, sip *airshipv1.SIPCluster, c client.Reader) error {
+	used, err := usedNodePorts(ctx, *sip, c)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]airshipv1.PortRange{}
+	for _, allocation := range sip.Status.NodePorts {
+		if allocation.Allocated {
+			previous[allocation.Service] = allocation.Ports
+		}
+	}
+
+	requests := nodePortRequests(sip)
+	allocations := make([]airshipv1.NodePortAllocation, len(requests))
+	conflicts := []string{}
+	// Node ports that are set in the spec are reserved first, so that they are not allocated to other services
+	for i, request := range requests {
+		allocations[i] = airshipv1.NodePortAllocation{Service: request.service, Ports: request.ports}
+		if request.ports == (airshipv1.PortRange{}) {
+			continue
+		}
+		if port, owner, inUse := used.inUse(request.ports); inUse {
+			conflicts = append(conflicts, fmt.Sprintf("node port %d of %s is in use by %s", port, request.service,
+				owner))
+			continue
+		}
+		used.reserve(request.ports, request.service)
+	}
+
+	for i, request := range requests {
+		if request.ports != (airshipv1.PortRange{}) {
+			continue
+		}
+		ports, found := previous[request.service]
+		if !found {
+			ports, found = previous[request.legacyService]
+		}
+		if _, _, inUse := used.inUse(ports); !found || inUse {
+			if ports, found = used.free(request.size); !found {
+				conflicts = append(conflicts, fmt.Sprintf("no %d free node ports are left for %s", request.size,
+					request.service))
+				continue
+			}
+		}
+		used.reserve(ports, request.service)
+		request.set(ports)
+		allocations[i].Ports = ports
+		allocations[i].Allocated = true
+	}
+
+	sip.Status.NodePorts = allocations
+	if len(conflicts) > 0 {
+		return ErrNodePortConflict{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// usedNodePorts returns the node ports of the Services in the cluster, other than the ones generated for the
+// SIPCluster, and the node ports that the other SIPClusters that are not being deleted set or allocated.
+func usedNodePorts(ctx context.Context, sip airshipv1.SIPCluster, c client.Reader) (nodePorts, error) {
+	used := nodePorts{}
+
+	services := &corev1.ServiceList{}
+	if err := c.List(ctx, services); err != nil {
+		return nil, err
+	}
+	for _, svc := range services.Items {
+		if _, generated := svc.Labels[ServiceLabel]; generated && svc.Namespace == sip.Namespace &&
+			svc.Labels[bmh.SipClusterNameLabel] == sip.Name {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.NodePort != 0 {
+				used[int(port.NodePort)] = fmt.Sprintf("Service %s/%s", svc.Namespace, svc.Name)
+			}
+		}
+	}
+
+	sips := &airshipv1.SIPClusterList{}
+	if err := c.List(ctx, sips); err != nil {
+		return nil, err
+	}
+	for i := range sips.Items {
+		other := &sips.Items[i]
+		// SIPClusters that are being deleted release their node ports once their Services are deleted
+		if (other.Namespace == sip.Namespace && other.Name == sip.Name) || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		owner := fmt.Sprintf("SIPCluster %s/%s", other.Namespace, other.Name)
+		for _, request := range nodePortRequests(other) {
+			used.reserve(request.ports, owner)
+		}
+		for _, allocation := range other.Status.NodePorts {
+			used.reserve(allocation.Ports, owner)
+		}
+	}
+
+	return used, nil
+}
+
+// nodePortRequests returns the node port requests of the infrastructure services of a SIPCluster, in the order of
+// the spec. ClusterIP services are not exposed on node ports and make no requests. Control plane load balancers
+// request a node port for each of their ports, or a single one for the API servers when they set no ports.
+func nodePortRequests(sip *airshipv1.SIPCluster) []nodePortRequest {
+	requests := []nodePortRequest{}
+	services := &sip.Spec.Services
+	for i := range services.JumpHost {
+		jumpHost := &services.JumpHost[i]
+		if jumpHost.ServiceType == corev1.ServiceTypeClusterIP {
+			continue
+		}
+		requests = append(requests, nodePortRequest{
+			service:       jumpHostName(sip.Name, i),
+			legacyService: fmt.Sprintf("jumpHost[%d]", i),
+			size:          1,
+			ports:         singlePort(jumpHost.NodePort),
+			set:           func(ports airshipv1.PortRange) { jumpHost.NodePort = ports.Start },
+		})
+	}
+	for i := range services.LoadBalancerControlPlane {
+		lb := &services.LoadBalancerControlPlane[i]
+		if lb.ServiceType == corev1.ServiceTypeClusterIP {
+			continue
+		}
+		name := loadBalancerName(airshipv1.RoleControlPlane, sip.Name, i)
+		if len(lb.Ports) == 0 {
+			requests = append(requests, nodePortRequest{
+				service:       name,
+				legacyService: fmt.Sprintf("loadBalancerControlPlane[%d]", i),
+				size:          1,
+				ports:         singlePort(lb.NodePort),
+				set:           func(ports airshipv1.PortRange) { lb.NodePort = ports.Start },
+			})
+		}
+		for j := range lb.Ports {
+			port := &lb.Ports[j]
+			requests = append(requests, nodePortRequest{
+				service:       name + "/" + port.Name,
+				legacyService: fmt.Sprintf("loadBalancerControlPlane[%d].ports[%d]", i, j),
+				size:          1,
+				ports:         singlePort(port.NodePort),
+				set:           func(ports airshipv1.PortRange) { port.NodePort = ports.Start },
+			})
+		}
+	}
+	for i := range services.LoadBalancerWorker {
+		lb := &services.LoadBalancerWorker[i]
+		if lb.ServiceType == corev1.ServiceTypeClusterIP {
+			continue
+		}
+		requests = append(requests, nodePortRequest{
+			service:       loadBalancerName(airshipv1.RoleWorker, sip.Name, i),
+			legacyService: fmt.Sprintf("loadBalancerWorker[%d]", i),
+			size:          airshipv1.DefaultWorkerNodePortRangeSize,
+			ports:         lb.NodePortRange,
+			set:           func(ports airshipv1.PortRange) { lb.NodePortRange = ports },
+		})
+	}
+	return requests
+}
+
+func singlePort(port int) airshipv1.PortRange {
+	if port == 0 {
+		return airshipv1.PortRange{}
+	}
+	return airshipv1.PortRange{Start: port, End: port}
+}
+
+// inUse returns the first port of a range that is in use, and its owner. An empty range is never in use.
+func (used nodePorts) inUse(ports airshipv1.PortRange) (int, string, bool) {
+	if ports == (airshipv1.PortRange{}) {
+		return 0, "", false
+	}
+	for port := ports.Start; port <= ports.End; port++ {
+		if owner, exists := used[port]; exists {
+			return port, owner, true
+		}
+	}
+	return 0, "", false
+}
+
+// reserve marks the ports of a range as used by owner.
+func (used nodePorts) reserve(ports airshipv1.PortRange, owner string) {
+	if ports == (airshipv1.PortRange{}) {
+		return
+	}
+	for port := ports.Start; port <= ports.End; port++ {
+		used[port] = owner
+	}
+}
+
+// free returns the lowest range of size consecutive node ports that are not in use.
+func (used nodePorts) free(size int) (airshipv1.PortRange, bool) {
+	start := airshipv1.NodePortRange.Start
+	for port := airshipv1.NodePortRange.Start; port <= airshipv1.NodePortRange.End; port++ {
+		if _, exists := used[port]; exists {
+			start = port + 1
+			continue
+		}
+		if port-start+1 == size {
+			return airshipv1.PortRange{Start: start, End: port}, true
+		}
+	}
+	return airshipv1.PortRange{}, false
+}
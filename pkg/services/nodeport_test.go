@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	"sipcluster/pkg/bmh"
+	"sipcluster/pkg/services"
+	"sipcluster/testutil"
+)
+
+var _ = Describe("Node port allocation", func() {
+	var sip *airshipv1.SIPCluster
+
+	BeforeEach(func() {
+		sip, _ = testutil.CreateSIPCluster("subcluster-1", "default", 1, 1)
+		sip.Spec.Services.JumpHost[0].NodePort = 0
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{}
+	})
+
+	newClient := func(objs ...runtime.Object) *fake.ClientBuilder {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...)
+	}
+
+	It("Allocates the lowest free node ports to services that omit them", func() {
+		other, _ := testutil.CreateSIPCluster("subcluster-2", "tenant", 1, 1)
+		other.Spec.Services.LoadBalancerControlPlane[0].NodePort = 30001
+		nodePortService := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "kube-system"},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeNodePort,
+				Ports: []corev1.ServicePort{{Port: 443, NodePort: 30012}},
+			},
+		}
+
+		Expect(services.AllocateNodePorts(context.Background(), sip,
+			newClient(other, nodePortService).Build())).To(Succeed())
+
+		// The other SIPCluster holds 30000-30011, and the Service 30012
+		Expect(sip.Spec.Services.JumpHost[0].NodePort).To(Equal(30013))
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].NodePort).To(Equal(30014))
+		Expect(sip.Spec.Services.LoadBalancerWorker[0].NodePortRange).To(Equal(
+			airshipv1.PortRange{Start: 30015, End: 30024}))
+		Expect(sip.Status.NodePorts).To(Equal([]airshipv1.NodePortAllocation{
+			{Service: "jumphost-subcluster-1", Ports: airshipv1.PortRange{Start: 30013, End: 30013}, Allocated: true},
+			{Service: "loadbalancer-controlplane-subcluster-1", Ports: airshipv1.PortRange{Start: 30014, End: 30014},
+				Allocated: true},
+			{Service: "loadbalancer-worker-subcluster-1", Ports: airshipv1.PortRange{Start: 30015, End: 30024},
+				Allocated: true},
+		}))
+	})
+
+	It("Allocates node ports from the configured node port range", func() {
+		defer func(nodePortRange airshipv1.PortRange) { airshipv1.NodePortRange = nodePortRange }(airshipv1.NodePortRange)
+		airshipv1.NodePortRange = airshipv1.PortRange{Start: 40000, End: 40011}
+		sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(sip.Spec.Services.JumpHost[0].NodePort).To(Equal(40000))
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].NodePort).To(Equal(40001))
+		Expect(sip.Spec.Services.LoadBalancerWorker[0].NodePortRange).To(Equal(
+			airshipv1.PortRange{Start: 40002, End: 40011}))
+	})
+
+	It("Keeps the node ports that were allocated before", func() {
+		sip.Status.NodePorts = []airshipv1.NodePortAllocation{
+			{Service: "jumphost-subcluster-1", Ports: airshipv1.PortRange{Start: 31000, End: 31000}, Allocated: true},
+		}
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(sip.Spec.Services.JumpHost[0].NodePort).To(Equal(31000))
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].NodePort).To(Equal(30000))
+	})
+
+	It("Keeps the node ports that earlier versions recorded under the index of the service", func() {
+		sip.Status.NodePorts = []airshipv1.NodePortAllocation{
+			{Service: "jumpHost[0]", Ports: airshipv1.PortRange{Start: 31000, End: 31000}, Allocated: true},
+		}
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(sip.Spec.Services.JumpHost[0].NodePort).To(Equal(31000))
+		Expect(sip.Status.NodePorts[0].Service).To(Equal("jumphost-subcluster-1"))
+	})
+
+	It("Keeps the node ports of the ports of a control plane load balancer when they are reordered", func() {
+		lb := &sip.Spec.Services.LoadBalancerControlPlane[0]
+		lb.NodePort = 0
+		lb.Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443},
+			{Name: "konnectivity", FrontendPort: 8132},
+		}
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(lb.Ports[0].NodePort).To(Equal(30001))
+		Expect(lb.Ports[1].NodePort).To(Equal(30002))
+
+		sip.Spec.Services.JumpHost[0].NodePort = 0
+		lb.Ports = []airshipv1.LoadBalancerPort{
+			{Name: "konnectivity", FrontendPort: 8132},
+			{Name: "https", FrontendPort: 6443},
+		}
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(lb.Ports[0].NodePort).To(Equal(30002))
+		Expect(lb.Ports[1].NodePort).To(Equal(30001))
+	})
+
+	It("Allocates a node port to each port of a control plane load balancer", func() {
+		lb := &sip.Spec.Services.LoadBalancerControlPlane[0]
+		lb.NodePort = 0
+		lb.Ports = []airshipv1.LoadBalancerPort{
+			{Name: "https", FrontendPort: 6443},
+			{Name: "konnectivity", FrontendPort: 8132, NodePort: 30000},
+		}
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(lb.NodePort).To(BeZero())
+		Expect(lb.Ports[0].NodePort).To(Equal(30002))
+		Expect(lb.Ports[1].NodePort).To(Equal(30000))
+		Expect(sip.Status.NodePorts).To(Equal([]airshipv1.NodePortAllocation{
+			{Service: "jumphost-subcluster-1", Ports: airshipv1.PortRange{Start: 30001, End: 30001}, Allocated: true},
+			{Service: "loadbalancer-controlplane-subcluster-1/https", Ports: airshipv1.PortRange{Start: 30002, End: 30002},
+				Allocated: true},
+			{Service: "loadbalancer-controlplane-subcluster-1/konnectivity",
+				Ports: airshipv1.PortRange{Start: 30000, End: 30000}},
+			{Service: "loadbalancer-worker-subcluster-1", Ports: airshipv1.PortRange{Start: 30003, End: 30012},
+				Allocated: true},
+		}))
+	})
+
+	It("Does not allocate node ports to ClusterIP services", func() {
+		sip.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeClusterIP
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient().Build())).To(Succeed())
+		Expect(sip.Spec.Services.JumpHost[0].NodePort).To(BeZero())
+		Expect(sip.Spec.Services.LoadBalancerControlPlane[0].NodePort).To(Equal(30000))
+		Expect(sip.Status.NodePorts).To(HaveLen(2))
+	})
+
+	It("Ignores the Services that were generated for the SIPCluster", func() {
+		sip.Spec.Services.JumpHost[0].NodePort = 30000
+		generated := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jumphost-subcluster-1",
+				Namespace: "default",
+				Labels: map[string]string{
+					services.ServiceLabel:   "jumphost-subcluster-1",
+					bmh.SipClusterNameLabel: "subcluster-1",
+				},
+			},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeNodePort,
+				Ports: []corev1.ServicePort{{Port: 22, NodePort: 30000}},
+			},
+		}
+
+		Expect(services.AllocateNodePorts(context.Background(), sip, newClient(generated).Build())).To(Succeed())
+		Expect(sip.Status.NodePorts[0]).To(Equal(airshipv1.NodePortAllocation{
+			Service: "jumphost-subcluster-1", Ports: airshipv1.PortRange{Start: 30000, End: 30000},
+		}))
+	})
+
+	It("Reports node ports that are in use by another SIPCluster", func() {
+		other, _ := testutil.CreateSIPCluster("subcluster-2", "tenant", 1, 1)
+		sip.Spec.Services.LoadBalancerWorker[0].NodePortRange = airshipv1.PortRange{Start: 30005, End: 30006}
+
+		err := services.AllocateNodePorts(context.Background(), sip, newClient(other).Build())
+		Expect(err).To(MatchError(services.ErrNodePortConflict{Conflicts: []string{
+			"node port 30005 of loadbalancer-worker-subcluster-1 is in use by SIPCluster tenant/subcluster-2",
+		}}))
+	})
+})
@@ -0,0 +1,107 @@
+/*
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     https://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	airshipv1 "sipcluster/pkg/api/v1"
+	bmh "sipcluster/pkg/bmh"
+)
+
+// RenderInputs holds the objects, other than the SIPCluster and its hosts, that infrastructure services are rendered
+// from.
+type RenderInputs struct {
+	// ControlPlaneTemplates holds the configuration templates of the control plane load balancers, by engine.
+	ControlPlaneTemplates map[airshipv1.LoadBalancerEngine]string
+	// WorkerTemplates holds the configuration templates of the worker load balancers, by engine.
+	WorkerTemplates map[airshipv1.LoadBalancerEngine]string
+	// NodeSSHPrivateKeys holds the Secrets named by the nodeSSHPrivateKeys field of the jump hosts, by name.
+	NodeSSHPrivateKeys map[string]corev1.Secret
+}
+
+// LoadRenderInputs reads the render inputs of a SIPCluster from its namespace: the load balancer template ConfigMaps,
+// which hold a template for every engine, and the jump host SSH private key Secrets. Inputs that do not exist are
+// left empty; a load balancer renders an empty configuration without its template, and a jump host fails to render
+// without its Secret.
+func LoadRenderInputs(sip airshipv1.SIPCluster, c client.Reader) (RenderInputs, error) {
+	inputs := RenderInputs{
+		ControlPlaneTemplates: make(map[airshipv1.LoadBalancerEngine]string),
+		WorkerTemplates:       make(map[airshipv1.LoadBalancerEngine]string),
+		NodeSSHPrivateKeys:    make(map[string]corev1.Secret),
+	}
+
+	templates := []struct {
+		configMap string
+		role      airshipv1.BMHRole
+		templates map[airshipv1.LoadBalancerEngine]string
+	}{
+		{ControlPlaneTemplateConfigMapName, airshipv1.RoleControlPlane, inputs.ControlPlaneTemplates},
+		{WorkerTemplateConfigMapName, airshipv1.RoleWorker, inputs.WorkerTemplates},
+	}
+	for _, t := range templates {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(context.Background(), client.ObjectKey{Name: t.configMap, Namespace: sip.GetNamespace()}, cm)
+		switch {
+		case apierror.IsNotFound(err):
+			continue
+		case err != nil:
+			return RenderInputs{}, err
+		}
+		for kind, engine := range engines {
+			if template, exists := cm.Data[engine.templateKey(t.role)]; exists {
+				t.templates[kind] = template
+			}
+		}
+	}
+
+	for _, jumpHost := range sip.Spec.Services.JumpHost {
+		secret := &corev1.Secret{}
+		err := c.Get(context.Background(), client.ObjectKey{
+			Name:      jumpHost.NodeSSHPrivateKeys,
+			Namespace: sip.GetNamespace(),
+		}, secret)
+		switch {
+		case apierror.IsNotFound(err):
+			continue
+		case err != nil:
+			return RenderInputs{}, err
+		}
+		inputs.NodeSSHPrivateKeys[jumpHost.NodeSSHPrivateKeys] = *secret
+	}
+
+	return inputs, nil
+}
+
+// Render returns the Kubernetes objects of every infrastructure service of a SIPCluster, in the order they are
+// applied, without reading from or writing to a cluster. The machines must have their service addresses and BMC
+// credentials extrapolated.
+func Render(logger logr.Logger, sip airshipv1.SIPCluster, machines *bmh.MachineList,
+	inputs RenderInputs) ([]client.Object, error) {
+	objs := []client.Object{}
+	for _, svc := range newServiceList(logger, sip, machines, inputs, nil) {
+		svcObjs, err := svc.Render()
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, svcObjs...)
+	}
+	return objs, nil
+}
@@ -3,7 +3,9 @@ package services_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strings"
 
 	airshipv1 "sipcluster/pkg/api/v1"
@@ -13,8 +15,19 @@ import (
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"sipcluster/pkg/bmh"
 	"sipcluster/pkg/services"
@@ -108,6 +121,9 @@ var _ = Describe("Service Set", func() {
 		Expect(k8sClient.DeleteAllOf(context.Background(), &airshipv1.SIPCluster{}, opts...)).Should(Succeed())
 		Expect(k8sClient.DeleteAllOf(context.Background(), &corev1.Secret{}, opts...)).Should(Succeed())
 		Expect(k8sClient.DeleteAllOf(context.Background(), &corev1.ConfigMap{}, opts...)).Should(Succeed())
+		Expect(k8sClient.DeleteAllOf(context.Background(), &corev1.Endpoints{}, opts...)).Should(Succeed())
+		Expect(k8sClient.DeleteAllOf(context.Background(), &appsv1.Deployment{}, opts...)).Should(Succeed())
+		Expect(k8sClient.DeleteAllOf(context.Background(), &policyv1beta1.PodDisruptionBudget{}, opts...)).Should(Succeed())
 	})
 
 	Context("When new SIP cluster is created", func() {
@@ -138,6 +154,77 @@ var _ = Describe("Service Set", func() {
 			}, 5, 1).Should(Succeed())
 		})
 
+		It("Records an event for each service that is deployed for the first time", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+			sipCluster.Status.Services = []airshipv1.ServiceStatus{
+				{Name: services.JumpHostServiceName + "-" + sipCluster.GetName()},
+			}
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			recorder := record.NewFakeRecorder(len(serviceList))
+			Expect(set.Deploy(serviceList, recorder)).To(Succeed())
+
+			Expect([]string{<-recorder.Events, <-recorder.Events}).To(ConsistOf(
+				"Normal ServiceDeployed Deployed service "+services.LoadBalancerServiceName+"-controlplane-default",
+				"Normal ServiceDeployed Deployed service "+services.LoadBalancerServiceName+"-worker-default",
+			))
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("Recreates the Deployment of a load balancer that switches engines", func() {
+			sipCluster, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sipCluster.Spec.Services.JumpHost = nil
+			sipCluster.Spec.Services.LoadBalancerWorker = nil
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(set.Deploy(serviceList, nil)).To(Succeed())
+
+			sipCluster.Spec.Services.LoadBalancerControlPlane[0].Engine = airshipv1.EngineNGINX
+			set = services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err = set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(set.Deploy(serviceList, nil)).To(Succeed())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Namespace: "default",
+				Name:      services.LoadBalancerServiceName + "-controlplane-" + sipCluster.GetName(),
+			}, deployment)).To(Succeed())
+			Expect(deployment.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "nginx"))
+		})
+
+		It("Removes the metrics Service of a load balancer that no longer exports metrics", func() {
+			sipCluster, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sipCluster.Spec.Services.JumpHost = nil
+			sipCluster.Spec.Services.LoadBalancerWorker = nil
+			sipCluster.Spec.Services.LoadBalancerControlPlane[0].Metrics = &airshipv1.MetricsOpts{}
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(set.Deploy(serviceList, nil)).To(Succeed())
+
+			metricsService := types.NamespacedName{
+				Namespace: "default",
+				Name:      services.LoadBalancerServiceName + "-controlplane-" + sipCluster.GetName() + "-metrics",
+			}
+			Expect(k8sClient.Get(context.Background(), metricsService, &corev1.Service{})).To(Succeed())
+
+			sipCluster.Spec.Services.LoadBalancerControlPlane[0].Metrics = nil
+			set = services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err = set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(set.Deploy(serviceList, nil)).To(Succeed())
+
+			err = k8sClient.Get(context.Background(), metricsService, &corev1.Service{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
 		It("Does not deploy a Jump Host when an invalid SSH key is provided", func() {
 			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
 			sip.Spec.Services.LoadBalancerControlPlane = []airshipv1.LoadBalancerServiceControlPlane{}
@@ -156,6 +243,799 @@ var _ = Describe("Service Set", func() {
 			}
 		})
 
+		It("Sets the SIPCluster as the owner of the objects of every service", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), sipCluster)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}, deployment)).To(Succeed())
+			Expect(deployment.GetOwnerReferences()).To(HaveLen(1))
+			Expect(deployment.GetOwnerReferences()[0].UID).To(Equal(sipCluster.GetUID()))
+		})
+	})
+
+	Context("When a SIP cluster is deleted", func() {
+		It("Removes the objects of every service", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+			for _, svc := range serviceList {
+				Expect(svc.Finalize()).To(Succeed())
+			}
+
+			for _, name := range []string{
+				services.LoadBalancerServiceName + "-controlplane-" + sipCluster.GetName(),
+				services.LoadBalancerServiceName + "-worker-" + sipCluster.GetName(),
+				services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			} {
+				err := k8sClient.Get(context.Background(), types.NamespacedName{
+					Namespace: "default",
+					Name:      name,
+				}, &appsv1.Deployment{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("When a SIP cluster is deleted through a cached client", func() {
+		It("Finalizes the services before the cache observes the deletions", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+
+			cached := newLaggingClient(k8sClient)
+			set = services.NewServiceSet(logger, *sipCluster, machineList, cached)
+			Expect(set.Finalize()).To(Succeed())
+			// The cache still lists the deleted objects on the next reconcile
+			Expect(set.Finalize()).To(Succeed())
+
+			err = k8sClient.Get(context.Background(), types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}, &appsv1.Deployment{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When a SIP cluster with the Retain reclaim policy is deleted", func() {
+		It("Retains the objects of every service without the SIP labels", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+			Expect(set.Orphan()).To(Succeed())
+
+			jumpHost := types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(context.Background(), jumpHost, deployment)).To(Succeed())
+			Expect(deployment.GetOwnerReferences()).To(BeEmpty())
+			Expect(deployment.GetLabels()).ToNot(HaveKey(bmh.SipClusterNameLabel))
+			Expect(deployment.GetLabels()).ToNot(HaveKey(services.ServiceLabel))
+			Expect(deployment.GetAnnotations()).To(HaveKeyWithValue(services.RetainedAnnotation, "default/default"))
+
+			By("Not pruning or taking over the retained objects for a new SIP cluster of the same name")
+			Expect(set.Finalize()).To(Succeed())
+			Expect(k8sClient.Get(context.Background(), jumpHost, deployment)).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(MatchError(ContainSubstring(
+					"was retained on deletion of SIPCluster default/default")))
+			}
+
+			// Services are not removed after each test
+			retained := &corev1.ServiceList{}
+			Expect(k8sClient.List(context.Background(), retained, client.InNamespace("default"))).To(Succeed())
+			for i := range retained.Items {
+				Expect(k8sClient.Delete(context.Background(), &retained.Items[i])).To(Succeed())
+			}
+		})
+	})
+
+	Context("When a service is removed from a SIP cluster", func() {
+		It("Prunes the objects of the service", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+
+			sipCluster.Spec.Services.JumpHost = nil
+			set = services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err = set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(serviceList).To(HaveLen(2))
+			Expect(set.Prune(serviceList)).To(Succeed())
+
+			jumpHost := types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}
+			for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.Secret{},
+				&corev1.ConfigMap{}} {
+				err := k8sClient.Get(context.Background(), jumpHost, obj)
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Namespace: "default",
+				Name:      services.LoadBalancerServiceName + "-worker-" + sipCluster.GetName(),
+			}, &appsv1.Deployment{})).To(Succeed())
+		})
+
+		It("Prunes the objects of the service before a cached client observes the deletions", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+
+			sipCluster.Spec.Services.JumpHost = nil
+			cached := newLaggingClient(k8sClient)
+			set = services.NewServiceSet(logger, *sipCluster, machineList, cached)
+			serviceList, err = set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(set.Prune(serviceList)).To(Succeed())
+			// Every reconcile prunes again, while the cache still lists the deleted objects
+			Expect(set.Prune(serviceList)).To(Succeed())
+
+			err = k8sClient.Get(context.Background(), types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}, &appsv1.Deployment{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When the status of a SIP cluster is reported", func() {
+		It("Reports the objects and node ports of every service", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			for _, svc := range serviceList {
+				Expect(svc.Deploy()).To(Succeed())
+			}
+
+			statuses, err := set.Status(serviceList)
+			Expect(err).To(Succeed())
+			Expect(statuses).To(HaveLen(len(serviceList)))
+
+			jumpHost := services.JumpHostServiceName + "-" + sipCluster.GetName()
+			for _, status := range statuses {
+				Expect(status.Objects).To(ContainElement(airshipv1.ServiceObject{Kind: "Deployment", Name: status.Name}))
+				// The test environment does not run the Deployment controller, so no replica becomes available
+				Expect(status.Ready).To(BeFalse())
+				Expect(apimeta.FindStatusCondition(status.Conditions, airshipv1.ConditionTypeReady).Reason).To(
+					Equal(airshipv1.ReasonTypeDeploymentUnavailable))
+				if status.Name == jumpHost {
+					Expect(status.NodePorts).To(Equal([]int32{30000}))
+				}
+			}
+		})
+		It("Reports a service as ready once its Deployment is rolled out and its Service has endpoints", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sipCluster.Spec.Services.LoadBalancerControlPlane = nil
+			sipCluster.Spec.Services.LoadBalancerWorker = nil
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(serviceList).To(HaveLen(1))
+			Expect(serviceList[0].Deploy()).To(Succeed())
+
+			jumpHost := types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(context.Background(), jumpHost, deployment)).To(Succeed())
+			deployment.Status.ObservedGeneration = deployment.Generation
+			deployment.Status.Replicas = 1
+			deployment.Status.UpdatedReplicas = 1
+			deployment.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(context.Background(), deployment)).To(Succeed())
+
+			statuses, err := set.Status(serviceList)
+			Expect(err).To(Succeed())
+			Expect(statuses[0].Ready).To(BeFalse())
+			Expect(apimeta.FindStatusCondition(statuses[0].Conditions, airshipv1.ConditionTypeReady).Reason).To(
+				Equal(airshipv1.ReasonTypeEndpointsUnavailable))
+
+			Expect(k8sClient.Create(context.Background(), &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Namespace: jumpHost.Namespace, Name: jumpHost.Name},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+					Ports:     []corev1.EndpointPort{{Port: 22}},
+				}},
+			})).To(Succeed())
+
+			statuses, err = set.Status(serviceList)
+			Expect(err).To(Succeed())
+			Expect(statuses[0].Ready).To(BeTrue())
+			Expect(apimeta.IsStatusConditionTrue(statuses[0].Conditions, airshipv1.ConditionTypeReady)).To(BeTrue())
+		})
+		It("Reports the address of a LoadBalancer service once it is assigned", func() {
+			sipCluster, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sipCluster.Spec.Services.LoadBalancerControlPlane = nil
+			sipCluster.Spec.Services.LoadBalancerWorker = nil
+			sipCluster.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeLoadBalancer
+			sipCluster.Spec.Services.JumpHost[0].ExternalIPs = []string{"192.168.10.21"}
+			Expect(k8sClient.Create(context.Background(), nodeSSHPrivateKeys)).Should(Succeed())
+
+			set := services.NewServiceSet(logger, *sipCluster, machineList, k8sClient)
+			serviceList, err := set.ServiceList()
+			Expect(err).To(Succeed())
+			Expect(serviceList[0].Deploy()).To(Succeed())
+
+			jumpHost := types.NamespacedName{
+				Namespace: "default",
+				Name:      services.JumpHostServiceName + "-" + sipCluster.GetName(),
+			}
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(context.Background(), jumpHost, deployment)).To(Succeed())
+			deployment.Status.ObservedGeneration = deployment.Generation
+			deployment.Status.Replicas = 1
+			deployment.Status.UpdatedReplicas = 1
+			deployment.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(context.Background(), deployment)).To(Succeed())
+			Expect(k8sClient.Create(context.Background(), &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Namespace: jumpHost.Namespace, Name: jumpHost.Name},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+					Ports:     []corev1.EndpointPort{{Port: 22}},
+				}},
+			})).To(Succeed())
+
+			statuses, err := set.Status(serviceList)
+			Expect(err).To(Succeed())
+			Expect(statuses[0].Ready).To(BeFalse())
+			Expect(apimeta.FindStatusCondition(statuses[0].Conditions, airshipv1.ConditionTypeReady).Reason).To(
+				Equal(airshipv1.ReasonTypeLoadBalancerPending))
+			Expect(statuses[0].Addresses).To(Equal([]string{"192.168.10.21"}))
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(context.Background(), jumpHost, service)).To(Succeed())
+			service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.168.10.20"}}
+			Expect(k8sClient.Status().Update(context.Background(), service)).To(Succeed())
+
+			statuses, err = set.Status(serviceList)
+			Expect(err).To(Succeed())
+			Expect(statuses[0].Ready).To(BeTrue())
+			Expect(statuses[0].Addresses).To(Equal([]string{"192.168.10.20", "192.168.10.21"}))
+		})
+	})
+
+	Context("When rendering services without a cluster", func() {
+		It("Renders the objects of every service in the order they are applied", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			inputs := services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: "{{ range .Servers }}server {{ .Name }} {{ .IP }}\n{{ end }}",
+				},
+				NodeSSHPrivateKeys: map[string]corev1.Secret{
+					nodeSSHPrivateKeys.Name: *nodeSSHPrivateKeys,
+				},
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, inputs)
+			Expect(err).ToNot(HaveOccurred())
+
+			rendered := []string{}
+			for _, obj := range objs {
+				rendered = append(rendered, fmt.Sprintf("%T %s", obj, obj.GetName()))
+			}
+			lbControlPlane := services.LoadBalancerServiceName + "-controlplane-" + sip.GetName()
+			lbWorker := services.LoadBalancerServiceName + "-worker-" + sip.GetName()
+			jumpHost := services.JumpHostServiceName + "-" + sip.GetName()
+			Expect(rendered).To(Equal([]string{
+				"*v1.Secret " + lbControlPlane,
+				"*v1.Deployment " + lbControlPlane,
+				"*v1.Service " + lbControlPlane,
+				"*v1beta1.PodDisruptionBudget " + lbControlPlane,
+				"*v1.Secret " + lbWorker,
+				"*v1.Deployment " + lbWorker,
+				"*v1.Service " + lbWorker,
+				"*v1beta1.PodDisruptionBudget " + lbWorker,
+				"*v1.Service " + jumpHost,
+				"*v1.Secret " + jumpHost,
+				"*v1.ConfigMap " + jumpHost,
+				"*v1.Deployment " + jumpHost,
+			}))
+
+			for _, obj := range objs {
+				Expect(obj.GetLabels()).To(HaveKeyWithValue(bmh.SipClusterNameLabel, sip.GetName()))
+				Expect(obj.GetLabels()).To(HaveKey(services.ServiceLabel))
+			}
+
+			lbSecret, ok := objs[0].(*corev1.Secret)
+			Expect(ok).To(BeTrue())
+			Expect(string(lbSecret.Data["haproxy.cfg"])).To(Equal(
+				"server " + bmh1.GetName() + " " + ip1 + "\nserver " + bmh2.GetName() + " " + ip2 + "\n"))
+		})
+
+		It("Renders the Services with the configured type and addresses", func() {
+			sip, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.LoadBalancerWorker = nil
+			sip.Spec.Services.LoadBalancerControlPlane[0].ServiceType = corev1.ServiceTypeLoadBalancer
+			sip.Spec.Services.LoadBalancerControlPlane[0].LoadBalancerIP = "192.168.10.20"
+			sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 30001
+			sip.Spec.Services.JumpHost[0].ServiceType = corev1.ServiceTypeClusterIP
+			sip.Spec.Services.JumpHost[0].ExternalIPs = []string{"192.168.10.21"}
+			inputs := services.RenderInputs{
+				NodeSSHPrivateKeys: map[string]corev1.Secret{
+					nodeSSHPrivateKeys.Name: *nodeSSHPrivateKeys,
+				},
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, inputs)
+			Expect(err).ToNot(HaveOccurred())
+
+			specs := map[string]corev1.ServiceSpec{}
+			for _, obj := range objs {
+				if service, ok := obj.(*corev1.Service); ok {
+					specs[service.Name] = service.Spec
+				}
+			}
+			lbControlPlane := specs[services.LoadBalancerServiceName+"-controlplane-"+sip.GetName()]
+			Expect(lbControlPlane.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
+			Expect(lbControlPlane.LoadBalancerIP).To(Equal("192.168.10.20"))
+			Expect(lbControlPlane.Ports[0].NodePort).To(Equal(int32(30001)))
+			jumpHost := specs[services.JumpHostServiceName+"-"+sip.GetName()]
+			Expect(jumpHost.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(jumpHost.ExternalIPs).To(Equal([]string{"192.168.10.21"}))
+			Expect(jumpHost.Ports[0].NodePort).To(BeZero())
+		})
+
+		It("Places the pods of every service as configured", func() {
+			sip, nodeSSHPrivateKeys := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.LoadBalancerWorker = nil
+			infraNodes := map[string]string{"node-role.kubernetes.io/infra": ""}
+			infraToleration := []corev1.Toleration{
+				{Key: "node-role.kubernetes.io/infra", Effect: corev1.TaintEffectNoSchedule},
+			}
+			sip.Spec.Services.LoadBalancerControlPlane[0].NodeLabels = infraNodes
+			sip.Spec.Services.LoadBalancerControlPlane[0].Tolerations = infraToleration
+			sip.Spec.Services.JumpHost[0].Affinity = &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "jump-host"}},
+						TopologyKey:   "kubernetes.io/hostname",
+					}},
+				},
+			}
+			inputs := services.RenderInputs{
+				NodeSSHPrivateKeys: map[string]corev1.Secret{
+					nodeSSHPrivateKeys.Name: *nodeSSHPrivateKeys,
+				},
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, inputs)
+			Expect(err).ToNot(HaveOccurred())
+
+			pods := map[string]corev1.PodSpec{}
+			for _, obj := range objs {
+				if deployment, ok := obj.(*appsv1.Deployment); ok {
+					pods[deployment.Name] = deployment.Spec.Template.Spec
+				}
+			}
+			lbControlPlane := pods[services.LoadBalancerServiceName+"-controlplane-"+sip.GetName()]
+			Expect(lbControlPlane.NodeSelector).To(Equal(infraNodes))
+			Expect(lbControlPlane.Tolerations).To(Equal(infraToleration))
+			Expect(lbControlPlane.Affinity).To(BeNil())
+			jumpHost := pods[services.JumpHostServiceName+"-"+sip.GetName()]
+			Expect(jumpHost.NodeSelector).To(BeEmpty())
+			Expect(jumpHost.Affinity).To(Equal(sip.Spec.Services.JumpHost[0].Affinity))
+		})
+
+		It("Names every load balancer of a role differently", func() {
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerControlPlane = append(sip.Spec.Services.LoadBalancerControlPlane,
+				*sip.Spec.Services.LoadBalancerControlPlane[0].DeepCopy())
+			sip.Spec.Services.LoadBalancerControlPlane[1].NodePort = 30002
+			sip.Spec.Services.LoadBalancerWorker = append(sip.Spec.Services.LoadBalancerWorker,
+				*sip.Spec.Services.LoadBalancerWorker[0].DeepCopy())
+			sip.Spec.Services.LoadBalancerWorker[1].NodePortRange = airshipv1.PortRange{Start: 30020, End: 30021}
+
+			serviceList, err := services.NewServiceSet(logger, *sip, machineList, k8sClient).ServiceList()
+			Expect(err).ToNot(HaveOccurred())
+			names := []string{}
+			for _, svc := range serviceList {
+				names = append(names, svc.Name())
+			}
+			Expect(names).To(Equal([]string{
+				services.LoadBalancerServiceName + "-controlplane-" + sip.GetName(),
+				services.LoadBalancerServiceName + "-controlplane1-" + sip.GetName(),
+				services.LoadBalancerServiceName + "-worker-" + sip.GetName(),
+				services.LoadBalancerServiceName + "-worker1-" + sip.GetName(),
+			}))
+		})
+
+		It("Names every jump host differently", func() {
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.LoadBalancerControlPlane = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			sip.Spec.Services.JumpHost = append(sip.Spec.Services.JumpHost,
+				*sip.Spec.Services.JumpHost[0].DeepCopy())
+			sip.Spec.Services.JumpHost[1].NodePort = 30003
+
+			serviceList, err := services.NewServiceSet(logger, *sip, machineList, k8sClient).ServiceList()
+			Expect(err).ToNot(HaveOccurred())
+			names := []string{}
+			for _, svc := range serviceList {
+				names = append(names, svc.Name())
+			}
+			Expect(names).To(Equal([]string{
+				services.JumpHostServiceName + "-" + sip.GetName(),
+				services.JumpHostServiceName + "1-" + sip.GetName(),
+			}))
+		})
+
+		It("Keeps the pod anti-affinity of a load balancer with several replicas", func() {
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			zoneTerm := corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+				TopologyKey:   "topology.kubernetes.io/zone",
+			}
+			lb := &sip.Spec.Services.LoadBalancerControlPlane[0]
+			lb.Replicas = 2
+			lb.Affinity = &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{zoneTerm},
+				},
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, services.RenderInputs{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var haproxy *appsv1.Deployment
+			for _, obj := range objs {
+				if deployment, ok := obj.(*appsv1.Deployment); ok {
+					haproxy = deployment
+				}
+			}
+			Expect(haproxy).ToNot(BeNil())
+			Expect(haproxy.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(
+				ConsistOf(zoneTerm, corev1.PodAffinityTerm{
+					LabelSelector: haproxy.Spec.Selector,
+					TopologyKey:   corev1.LabelHostname,
+				}))
+			Expect(lb.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+		})
+
+		It("Renders a highly available control plane load balancer with a virtual IP", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			virtualIP := "192.168.10.30"
+			lb := &sip.Spec.Services.LoadBalancerControlPlane[0]
+			lb.Replicas = 3
+			lb.ClusterIP = &virtualIP
+			lb.VirtualIP = &airshipv1.VirtualIPOpts{
+				Interface:       "bond0",
+				VirtualRouterID: 51,
+				Image:           "osixia/keepalived:2.0.20",
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: "{{ range .Servers }}server {{ .Name }} {{ .IP }}\n{{ end }}",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			lbControlPlane := services.LoadBalancerServiceName + "-controlplane-" + sip.GetName()
+			keepalived := lbControlPlane + "-keepalived"
+			rendered := map[string]client.Object{}
+			for _, obj := range objs {
+				rendered[fmt.Sprintf("%T %s", obj, obj.GetName())] = obj
+				Expect(obj.GetLabels()).To(HaveKeyWithValue(services.ServiceLabel, lbControlPlane))
+			}
+			Expect(rendered).To(HaveLen(7))
+
+			haproxy := rendered["*v1.Deployment "+lbControlPlane].(*appsv1.Deployment)
+			Expect(*haproxy.Spec.Replicas).To(Equal(int32(3)))
+			Expect(haproxy.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(
+				ConsistOf(corev1.PodAffinityTerm{
+					LabelSelector: haproxy.Spec.Selector,
+					TopologyKey:   corev1.LabelHostname,
+				}))
+			Expect(haproxy.Spec.Strategy.RollingUpdate.MaxSurge.IntValue()).To(BeZero())
+			Expect(rendered).To(HaveKey("*v1beta1.PodDisruptionBudget " + lbControlPlane))
+
+			service := rendered["*v1.Service "+lbControlPlane].(*corev1.Service)
+			Expect(service.Spec.ClusterIP).To(BeEmpty())
+			Expect(service.Spec.ExternalIPs).To(Equal([]string{virtualIP}))
+
+			keepalivedDeployment := rendered["*v1.Deployment "+keepalived].(*appsv1.Deployment)
+			Expect(*keepalivedDeployment.Spec.Replicas).To(Equal(int32(3)))
+			Expect(keepalivedDeployment.Spec.Template.Spec.HostNetwork).To(BeTrue())
+			Expect(keepalivedDeployment.Spec.Template.Spec.Affinity.PodAntiAffinity.
+				RequiredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+			Expect(keepalivedDeployment.Spec.Strategy.RollingUpdate.MaxSurge.IntValue()).To(BeZero())
+			Expect(keepalivedDeployment.Spec.Template.Spec.Containers[0].Image).To(Equal("osixia/keepalived:2.0.20"))
+			keepalivedConfig := rendered["*v1.ConfigMap "+keepalived].(*corev1.ConfigMap)
+			Expect(keepalivedConfig.Data["keepalived.conf"]).To(And(
+				ContainSubstring("interface bond0"),
+				ContainSubstring("virtual_router_id 51"),
+				ContainSubstring(virtualIP),
+			))
+			Expect(rendered).To(HaveKey("*v1beta1.PodDisruptionBudget " + keepalived))
+		})
+
+		It("Renders a frontend and a backend for each port of a control plane load balancer", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			sip.Spec.Services.LoadBalancerControlPlane[0].NodePort = 0
+			sip.Spec.Services.LoadBalancerControlPlane[0].Ports = []airshipv1.LoadBalancerPort{
+				{Name: "https", FrontendPort: 6443, NodePort: 30001, HealthCheck: airshipv1.HealthCheckHTTP,
+					HealthCheckPath: "/livez", TLSCheck: true},
+				{Name: "konnectivity", FrontendPort: 8132, NodePort: 30002},
+				{Name: "ingress", FrontendPort: 443, BackendPort: 30443, NodePort: 30003,
+					HealthCheck: airshipv1.HealthCheckNone},
+			}
+			template, err := ioutil.ReadFile("../../config/manager/loadbalancer/loadBalancerControlPlane.cfg")
+			Expect(err).ToNot(HaveOccurred())
+
+			objs, err := services.Render(logger, *sip, machineList, services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: string(template),
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			secret, ok := objs[0].(*corev1.Secret)
+			Expect(ok).To(BeTrue())
+			config := string(secret.Data["haproxy.cfg"])
+			backend := func(name string) string {
+				start := strings.Index(config, "backend "+name+"-backend")
+				Expect(start).ToNot(Equal(-1))
+				end := strings.Index(config[start+1:], "frontend ")
+				if end == -1 {
+					return config[start:]
+				}
+				return config[start : start+1+end]
+			}
+			Expect(config).To(And(
+				ContainSubstring("frontend https-frontend\n  bind *:6443\n"),
+				ContainSubstring("frontend konnectivity-frontend\n  bind *:8132\n"),
+				ContainSubstring("frontend ingress-frontend\n  bind *:443\n"),
+			))
+			Expect(backend("https")).To(And(
+				ContainSubstring("option httpchk GET /livez"),
+				ContainSubstring("default-server check check-ssl verify none inter 5s"),
+				ContainSubstring(ip1+":6443"),
+			))
+			Expect(backend("konnectivity")).To(And(
+				Not(ContainSubstring("httpchk")),
+				ContainSubstring("default-server check inter 5s"),
+				ContainSubstring(ip2+":8132"),
+			))
+			Expect(backend("ingress")).To(And(
+				ContainSubstring("default-server inter 5s"),
+				ContainSubstring(ip1+":30443"),
+			))
+
+			deployment, ok := objs[1].(*appsv1.Deployment)
+			Expect(ok).To(BeTrue())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Ports).To(Equal([]corev1.ContainerPort{
+				{Name: "https", ContainerPort: 6443},
+				{Name: "konnectivity", ContainerPort: 8132},
+				{Name: "ingress", ContainerPort: 443},
+			}))
+			service, ok := objs[2].(*corev1.Service)
+			Expect(ok).To(BeTrue())
+			Expect(service.Spec.Ports).To(Equal([]corev1.ServicePort{
+				{Name: "https", Port: 6443, NodePort: 30001},
+				{Name: "konnectivity", Port: 8132, NodePort: 30002},
+				{Name: "ingress", Port: 443, NodePort: 30003},
+			}))
+		})
+
+		It("Renders load balancers that run NGINX", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			sip.Spec.Services.LoadBalancerControlPlane[0].Engine = airshipv1.EngineNGINX
+			template, err := ioutil.ReadFile("../../config/manager/loadbalancer/loadBalancerControlPlane.nginx.conf")
+			Expect(err).ToNot(HaveOccurred())
+
+			objs, err := services.Render(logger, *sip, machineList, services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: "unused",
+					airshipv1.EngineNGINX:   string(template),
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			secret, ok := objs[0].(*corev1.Secret)
+			Expect(ok).To(BeTrue())
+			Expect(secret.Data).To(HaveLen(1))
+			Expect(string(secret.Data["nginx.conf"])).To(And(
+				ContainSubstring("server "+ip1+":6443 max_fails=4 fail_timeout=10s;"),
+				ContainSubstring("server "+ip2+":6443 max_fails=4 fail_timeout=10s;"),
+				ContainSubstring("listen 6443;"),
+			))
+
+			deployment, ok := objs[1].(*appsv1.Deployment)
+			Expect(ok).To(BeTrue())
+			Expect(deployment.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "nginx"))
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal(airshipv1.DefaultNGINXLoadBalancerImage))
+			Expect(container.Command).To(Equal([]string{"nginx", "-c", "/etc/nginx/sip/nginx.conf", "-g", "daemon off;"}))
+			Expect(container.VolumeMounts[0].MountPath).To(Equal("/etc/nginx/sip"))
+		})
+
+		It("Renders the metrics Service and ServiceMonitor of a load balancer", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			sip.Spec.Services.LoadBalancerControlPlane[0].Metrics = &airshipv1.MetricsOpts{
+				Port: 9101,
+				ServiceMonitor: &airshipv1.ServiceMonitorOpts{
+					Labels:   map[string]string{"release": "prometheus"},
+					Interval: "30s",
+				},
+			}
+
+			objs, err := services.Render(logger, *sip, machineList, services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: "{{ range .ContainerPorts }}frontend {{ .Name }}\n{{ end }}",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(objs).To(HaveLen(6))
+
+			secret, ok := objs[0].(*corev1.Secret)
+			Expect(ok).To(BeTrue())
+			// The metrics port is not forwarded to the API servers
+			Expect(string(secret.Data["haproxy.cfg"])).To(And(
+				HavePrefix("frontend http\n\nfrontend sip-metrics\n"),
+				ContainSubstring("bind *:9101"),
+				ContainSubstring("http-request use-service prometheus-exporter if { path /metrics }"),
+			))
+			deployment, ok := objs[1].(*appsv1.Deployment)
+			Expect(ok).To(BeTrue())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Ports).To(ContainElement(
+				corev1.ContainerPort{Name: "metrics", ContainerPort: 9101}))
+
+			lbControlPlane := services.LoadBalancerServiceName + "-controlplane-" + sip.GetName()
+			metricsService, ok := objs[4].(*corev1.Service)
+			Expect(ok).To(BeTrue())
+			Expect(metricsService.Name).To(Equal(lbControlPlane + "-metrics"))
+			Expect(metricsService.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(metricsService.Spec.Selector).To(Equal(deployment.Spec.Selector.MatchLabels))
+			Expect(metricsService.Spec.Ports).To(Equal([]corev1.ServicePort{{
+				Name:       "metrics",
+				Port:       9101,
+				TargetPort: intstr.FromString("metrics"),
+			}}))
+
+			monitor, ok := objs[5].(*unstructured.Unstructured)
+			Expect(ok).To(BeTrue())
+			Expect(monitor.GroupVersionKind()).To(Equal(services.ServiceMonitorGVK))
+			Expect(monitor.GetName()).To(Equal(lbControlPlane + "-metrics"))
+			Expect(monitor.GetLabels()).To(HaveKeyWithValue("release", "prometheus"))
+			Expect(monitor.GetLabels()).To(HaveKeyWithValue(services.ServiceLabel, lbControlPlane))
+			matchLabels, _, err := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(matchLabels).To(Equal(metricsService.Spec.Selector))
+			endpoints, _, err := unstructured.NestedSlice(monitor.Object, "spec", "endpoints")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoints).To(Equal([]interface{}{map[string]interface{}{
+				"port":     "metrics",
+				"path":     "/metrics",
+				"interval": "30s",
+			}}))
+		})
+
+		It("Loads the load balancer templates of every engine", func() {
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(
+				testutil.CreateTemplateConfigMap(services.ControlPlaneTemplateConfigMapName,
+					"loadBalancerControlPlane.cfg", "default", "haproxy control plane"),
+				testutil.CreateTemplateConfigMap(services.WorkerTemplateConfigMapName,
+					"loadBalancerWorker.nginx.conf", "default", "nginx worker"),
+			).Build()
+
+			inputs, err := services.LoadRenderInputs(*sip, c)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inputs.ControlPlaneTemplates).To(Equal(map[airshipv1.LoadBalancerEngine]string{
+				airshipv1.EngineHAProxy: "haproxy control plane",
+			}))
+			Expect(inputs.WorkerTemplates).To(Equal(map[airshipv1.LoadBalancerEngine]string{
+				airshipv1.EngineNGINX: "nginx worker",
+			}))
+		})
+
+		It("Annotates the load balancer pods with a hash of their configuration", func() {
+			m1.BMHRole = airshipv1.RoleControlPlane
+			m2.BMHRole = airshipv1.RoleControlPlane
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+			sip.Spec.Services.JumpHost = nil
+			sip.Spec.Services.LoadBalancerWorker = nil
+			inputs := services.RenderInputs{
+				ControlPlaneTemplates: map[airshipv1.LoadBalancerEngine]string{
+					airshipv1.EngineHAProxy: "{{ range .Servers }}server {{ .Name }} {{ .IP }}\n{{ end }}",
+				},
+			}
+			configHash := func() string {
+				objs, err := services.Render(logger, *sip, machineList, inputs)
+				Expect(err).ToNot(HaveOccurred())
+				deployment, ok := objs[1].(*appsv1.Deployment)
+				Expect(ok).To(BeTrue())
+				return deployment.Spec.Template.Annotations[services.ConfigHashAnnotation]
+			}
+
+			initial := configHash()
+			Expect(initial).ToNot(BeEmpty())
+			Expect(configHash()).To(Equal(initial))
+
+			// Removing a node changes the servers of the configuration
+			delete(machineList.Machines, bmh2.GetName())
+			Expect(configHash()).ToNot(Equal(initial))
+		})
+
+		It("Does not render a Jump Host without its SSH private keys", func() {
+			sip, _ := testutil.CreateSIPCluster("default", "default", 1, 1)
+
+			_, err := services.Render(logger, *sip, machineList, services.RenderInputs{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
 	})
 
 })
@@ -274,3 +1154,62 @@ func testDeployment(sip *airshipv1.SIPCluster, machineList bmh.MachineList) erro
 
 	return nil
 }
+
+// laggingClient is a client whose reads, like reads through the cache of a manager, have not observed the deletions
+// that were made through it yet.
+type laggingClient struct {
+	client.Client
+	deleted []client.Object
+}
+
+func newLaggingClient(c client.Client) *laggingClient {
+	return &laggingClient{Client: c}
+}
+
+func (c *laggingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.deleted = append(c.deleted, obj.DeepCopyObject().(client.Object))
+	return nil
+}
+
+func (c *laggingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	for _, deleted := range c.deleted {
+		if kindOf(deleted) == kindOf(obj) && client.ObjectKeyFromObject(deleted) == key {
+			reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(deleted.DeepCopyObject()).Elem())
+			return nil
+		}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *laggingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	for _, deleted := range c.deleted {
+		if kindOf(deleted)+"List" != kindOf(list) ||
+			(listOpts.Namespace != "" && deleted.GetNamespace() != listOpts.Namespace) ||
+			(listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(deleted.GetLabels()))) {
+			continue
+		}
+		items = append(items, deleted.DeepCopyObject())
+	}
+	return apimeta.SetList(list, items)
+}
+
+// kindOf returns the kind of an object or list, from its type when the kind is not set.
+func kindOf(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.TypeOf(obj).Elem().Name()
+}
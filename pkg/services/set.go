@@ -16,20 +16,47 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierror "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	airshipv1 "sipcluster/pkg/api/v1"
 	bmh "sipcluster/pkg/bmh"
+	"sipcluster/pkg/metrics"
+)
+
+const (
+	// ServiceLabel identifies the infrastructure service instance that an object was generated for. Objects are also
+	// labeled with the name of their SIPCluster, using the same label as scheduled BMHs.
+	ServiceLabel = bmh.BaseAirshipSelector + "/service"
+
+	// RetainedAnnotation records the SIPCluster that an object was generated for, on objects that were retained on
+	// deletion of the SIPCluster. Retained objects are not taken over by a new SIPCluster of the same name.
+	RetainedAnnotation = bmh.BaseAirshipSelector + "/retained-from"
 )
 
 // InfraService generalizes inftracture services
 type InfraService interface {
+	// Name returns the name of the service instance, after which its objects are named and labeled.
+	Name() string
 	Deploy() error
 	Finalize() error
+	// Render returns the Kubernetes objects of the service, in the order they are applied by Deploy.
+	Render() ([]client.Object, error)
 }
 
 // ServiceSet provides access to infrastructure services
@@ -56,56 +83,505 @@ func NewServiceSet(
 	}
 }
 
+// Reasons of the events that are recorded on a SIPCluster for its infrastructure services
+const (
+	// ReasonServiceDeployed is recorded when the objects of a service have been applied.
+	ReasonServiceDeployed = "ServiceDeployed"
+	// ReasonServiceFailed is recorded when the objects of a service cannot be rendered or applied.
+	ReasonServiceFailed = "ServiceFailed"
+)
+
+// Deploy deploys the given services in order and, if recorder is not nil, records an event on the SIPCluster for
+// each service that fails or that is deployed for the first time. It stops at the first service that fails.
+func (ss ServiceSet) Deploy(services []InfraService, recorder record.EventRecorder) error {
+	deployed := map[string]bool{}
+	for _, status := range ss.sip.Status.Services {
+		deployed[status.Name] = true
+	}
+
+	for _, svc := range services {
+		if err := svc.Deploy(); err != nil {
+			metrics.ServiceDeployErrors.WithLabelValues(ss.sip.Namespace, svc.Name()).Inc()
+			if recorder != nil {
+				recorder.Eventf(&ss.sip, corev1.EventTypeWarning, ReasonServiceFailed, "Unable to deploy service %s: %v",
+					svc.Name(), err)
+			}
+			return err
+		}
+		if recorder != nil && !deployed[svc.Name()] {
+			recorder.Eventf(&ss.sip, corev1.EventTypeNormal, ReasonServiceDeployed, "Deployed service %s", svc.Name())
+		}
+	}
+	return nil
+}
+
+// Finalize deletes every object that was generated for the SIPCluster, including the objects of services that were
+// removed from its spec.
 func (ss ServiceSet) Finalize() error {
+	return ss.Prune(nil)
+}
+
+// Prune deletes the objects that were generated for the SIPCluster by services other than the given ones, i.e. by
+// services that were removed from its spec.
+func (ss ServiceSet) Prune(services []InfraService) error {
+	keep := map[string]bool{}
+	for _, svc := range services {
+		keep[svc.Name()] = true
+	}
+
+	objs, err := ss.generatedObjects()
+	if err != nil {
+		return err
+	}
+	stale := []client.Object{}
+	for _, obj := range objs {
+		if !keep[obj.GetLabels()[ServiceLabel]] {
+			stale = append(stale, obj)
+		}
+	}
+
+	return deleteObjects(stale, ss.client, ss.logger)
+}
+
+// Orphan removes the owner reference to the SIPCluster and the SIP labels from every object that was generated for
+// it, so that the objects are kept when the SIPCluster is deleted, and are neither pruned nor reported by a new
+// SIPCluster of the same name. The objects are annotated with the SIPCluster instead.
+func (ss ServiceSet) Orphan() error {
+	objs, err := ss.generatedObjects()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		owners := []metav1.OwnerReference{}
+		for _, owner := range obj.GetOwnerReferences() {
+			if owner.UID != ss.sip.GetUID() {
+				owners = append(owners, owner)
+			}
+		}
+		labels := obj.GetLabels()
+		delete(labels, bmh.SipClusterNameLabel)
+		delete(labels, ServiceLabel)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[RetainedAnnotation] = ss.sip.GetNamespace() + "/" + ss.sip.GetName()
+
+		ss.logger.Info("Orphaning object", "kind", objectKind(obj),
+			"object", obj.GetNamespace()+"/"+obj.GetName())
+		obj.SetOwnerReferences(owners)
+		obj.SetLabels(labels)
+		obj.SetAnnotations(annotations)
+		if err = ss.client.Update(context.Background(), obj); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Status reports the objects, node ports, addresses and readiness of the given services, as found in the cluster. A
+// service is ready once its Deployments are rolled out, its Services have ready endpoints and its LoadBalancer
+// Services have been assigned an address.
+func (ss ServiceSet) Status(services []InfraService) ([]airshipv1.ServiceStatus, error) {
+	generated, err := ss.generatedObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []airshipv1.ServiceStatus{}
+	for _, svc := range services {
+		status := airshipv1.ServiceStatus{Name: svc.Name()}
+		objs := []client.Object{}
+		for _, obj := range generated {
+			if obj.GetLabels()[ServiceLabel] != svc.Name() {
+				continue
+			}
+			objs = append(objs, obj)
+			status.Objects = append(status.Objects, airshipv1.ServiceObject{
+				Kind: objectKind(obj),
+				Name: obj.GetName(),
+			})
+			if service, ok := obj.(*corev1.Service); ok {
+				for _, port := range service.Spec.Ports {
+					if port.NodePort != 0 {
+						status.NodePorts = append(status.NodePorts, port.NodePort)
+					}
+				}
+				status.Addresses = append(status.Addresses, serviceAddresses(service)...)
+			}
+		}
+		sort.Slice(status.Objects, func(i, j int) bool {
+			if status.Objects[i].Kind != status.Objects[j].Kind {
+				return status.Objects[i].Kind < status.Objects[j].Kind
+			}
+			return status.Objects[i].Name < status.Objects[j].Name
+		})
+		sort.Slice(status.NodePorts, func(i, j int) bool { return status.NodePorts[i] < status.NodePorts[j] })
+
+		condition, err := ss.readyCondition(svc.Name(), objs)
+		if err != nil {
+			return nil, err
+		}
+		status.Ready = condition.Status == metav1.ConditionTrue
+		apimeta.SetStatusCondition(&status.Conditions, condition)
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// readyCondition returns the Ready condition of a service from its objects. Only the Services of a service whose
+// Deployments are rolled out are checked for endpoints, and only Services with ready endpoints for a load balancer
+// address.
+func (ss ServiceSet) readyCondition(name string, objs []client.Object) (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:    airshipv1.ConditionTypeReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  airshipv1.ReasonTypeDeploymentUnavailable,
+		Message: fmt.Sprintf("waiting for the Deployment of %s to be created", name),
+	}
+
+	deployments := 0
+	unavailable := []string{}
+	for _, obj := range objs {
+		if deployment, ok := obj.(*appsv1.Deployment); ok {
+			deployments++
+			if msg := deploymentRollout(deployment); msg != "" {
+				unavailable = append(unavailable, msg)
+			}
+		}
+	}
+	if deployments == 0 {
+		return condition, nil
+	}
+	if len(unavailable) > 0 {
+		condition.Message = strings.Join(unavailable, "; ")
+		return condition, nil
+	}
+
+	condition.Reason = airshipv1.ReasonTypeEndpointsUnavailable
+	for _, obj := range objs {
+		service, ok := obj.(*corev1.Service)
+		if !ok {
+			continue
+		}
+		ready, err := ss.hasReadyEndpoints(service)
+		if err != nil {
+			return condition, err
+		}
+		if !ready {
+			unavailable = append(unavailable, fmt.Sprintf("Service %s has no ready endpoints", service.Name))
+		}
+	}
+	if len(unavailable) > 0 {
+		condition.Message = strings.Join(unavailable, "; ")
+		return condition, nil
+	}
+
+	if pending := pendingLoadBalancers(objs); len(pending) > 0 {
+		condition.Reason = airshipv1.ReasonTypeLoadBalancerPending
+		condition.Message = strings.Join(pending, "; ")
+		return condition, nil
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = airshipv1.ReasonTypeServiceAvailable
+	condition.Message = ""
+	return condition, nil
+}
+
+// pendingLoadBalancers lists the LoadBalancer Services among objs that have not been assigned an address yet.
+func pendingLoadBalancers(objs []client.Object) []string {
+	pending := []string{}
+	for _, obj := range objs {
+		service, ok := obj.(*corev1.Service)
+		if ok && service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+			pending = append(pending, fmt.Sprintf("Service %s has no load balancer address", service.Name))
+		}
+	}
+	return pending
+}
+
+// serviceAddresses returns the load balancer ingress addresses and external IPs that a Service is reachable on.
+func serviceAddresses(service *corev1.Service) []string {
+	addresses := []string{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		} else if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	return append(addresses, service.Spec.ExternalIPs...)
+}
+
+// deploymentRollout returns why a Deployment is not rolled out yet, or an empty string once every replica of its
+// latest generation is available.
+func deploymentRollout(deployment *appsv1.Deployment) string {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	switch {
+	case deployment.Status.ObservedGeneration < deployment.Generation:
+		return fmt.Sprintf("waiting for Deployment %s to be observed", deployment.Name)
+	case deployment.Status.UpdatedReplicas < replicas:
+		return fmt.Sprintf("Deployment %s has %d of %d replicas updated", deployment.Name,
+			deployment.Status.UpdatedReplicas, replicas)
+	case deployment.Status.AvailableReplicas < replicas:
+		return fmt.Sprintf("Deployment %s has %d of %d replicas available", deployment.Name,
+			deployment.Status.AvailableReplicas, replicas)
+	}
+	return ""
+}
+
+// hasReadyEndpoints reports whether a Service has at least one ready endpoint address.
+func (ss ServiceSet) hasReadyEndpoints(service *corev1.Service) (bool, error) {
+	endpoints := &corev1.Endpoints{}
+	err := ss.client.Get(context.Background(), client.ObjectKeyFromObject(service), endpoints)
+	if apierror.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// generatedObjects returns the objects that were generated for the SIPCluster by its services.
+func (ss ServiceSet) generatedObjects() ([]client.Object, error) {
+	generated := []client.Object{}
+	for _, list := range []client.ObjectList{
+		&appsv1.DeploymentList{},
+		&corev1.ServiceList{},
+		&corev1.SecretList{},
+		&corev1.ConfigMapList{},
+		&policyv1beta1.PodDisruptionBudgetList{},
+		serviceMonitorList(),
+	} {
+		err := ss.client.List(context.Background(), list,
+			client.InNamespace(ss.sip.GetNamespace()),
+			client.MatchingLabels{bmh.SipClusterNameLabel: ss.sip.GetName()},
+			client.HasLabels{ServiceLabel})
+		// There are no ServiceMonitors unless their CRD is installed
+		if apimeta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		objs, err := apimeta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			if obj, ok := obj.(client.Object); ok {
+				generated = append(generated, obj)
+			}
+		}
+	}
+	return generated, nil
+}
+
 // ServiceList returns all services defined in Set
 func (ss ServiceSet) ServiceList() ([]InfraService, error) {
+	inputs, err := LoadRenderInputs(ss.sip, ss.client)
+	if err != nil {
+		return nil, err
+	}
+	return newServiceList(ss.logger, ss.sip, ss.machines, inputs, ss.client), nil
+}
+
+func newServiceList(logger logr.Logger, sip airshipv1.SIPCluster, machines *bmh.MachineList, inputs RenderInputs,
+	c client.Client) []InfraService {
+	owner := ownerReference(sip)
 	serviceList := []InfraService{}
-	services := ss.sip.Spec.Services
-	for _, svc := range services.LoadBalancerControlPlane {
+	services := sip.Spec.Services
+	for i, svc := range services.LoadBalancerControlPlane {
 		serviceList = append(serviceList,
-			newLBControlPlane(ss.sip.GetName(),
-				ss.sip.GetNamespace(),
-				ss.logger,
+			newLBControlPlane(sip.GetName(),
+				sip.GetNamespace(),
+				i,
+				owner,
+				logger,
 				svc,
-				ss.machines,
-				ss.client))
+				machines,
+				inputs.ControlPlaneTemplates,
+				c))
 	}
-	for _, svc := range services.LoadBalancerWorker {
+	for i, svc := range services.LoadBalancerWorker {
 		serviceList = append(serviceList,
-			newLBWorker(ss.sip.GetName(),
-				ss.sip.GetNamespace(),
-				ss.logger,
+			newLBWorker(sip.GetName(),
+				sip.GetNamespace(),
+				i,
+				owner,
+				logger,
 				svc,
-				ss.machines,
-				ss.client))
+				machines,
+				inputs.WorkerTemplates,
+				c))
 	}
-	for _, svc := range services.JumpHost {
+	for i, svc := range services.JumpHost {
+		var nodeSSHPrivateKeys *corev1.Secret
+		if secret, exists := inputs.NodeSSHPrivateKeys[svc.NodeSSHPrivateKeys]; exists {
+			nodeSSHPrivateKeys = &secret
+		}
 		serviceList = append(serviceList,
-			newJumpHost(ss.sip.GetName(),
-				ss.sip.GetNamespace(),
-				ss.logger,
+			newJumpHost(sip.GetName(),
+				sip.GetNamespace(),
+				i,
+				owner,
+				logger,
 				svc,
-				ss.machines,
-				ss.client))
+				machines,
+				nodeSSHPrivateKeys,
+				c))
+	}
+	return serviceList
+}
+
+// ownerReference returns the controller reference that makes a SIPCluster the owner of the objects of its services,
+// so that they are garbage collected with it. SIPClusters that do not exist in a cluster, e.g. ones that are rendered
+// from a file, have no UID and get no owner reference.
+func ownerReference(sip airshipv1.SIPCluster) *metav1.OwnerReference {
+	if sip.GetUID() == "" {
+		return nil
 	}
-	return serviceList, nil
+	return metav1.NewControllerRef(&sip, airshipv1.GroupVersion.WithKind("SIPCluster"))
+}
+
+// setOwnership labels objs with the SIPCluster and the service instance that they were generated for, so that they
+// can be pruned, and makes owner their only owner.
+func setOwnership(objs []client.Object, sipName, instance string, owner *metav1.OwnerReference) {
+	for _, obj := range objs {
+		// Label maps may be shared with selectors, which must not change
+		labels := map[string]string{}
+		for k, v := range obj.GetLabels() {
+			labels[k] = v
+		}
+		labels[bmh.SipClusterNameLabel] = sipName
+		labels[ServiceLabel] = instance
+		obj.SetLabels(labels)
+
+		if owner != nil {
+			obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		}
+	}
+}
+
+// serviceSpec returns the spec of a Service that exposes ports of a service as configured in the SIPCluster. Ports of
+// ClusterIP Services are not exposed on node ports.
+func serviceSpec(config airshipv1.SIPClusterService, ports []corev1.ServicePort,
+	selector map[string]string) corev1.ServiceSpec {
+	spec := corev1.ServiceSpec{
+		Ports:          ports,
+		Selector:       selector,
+		Type:           config.ServiceType,
+		LoadBalancerIP: config.LoadBalancerIP,
+		ExternalIPs:    config.ExternalIPs,
+	}
+	if spec.Type == "" {
+		spec.Type = corev1.ServiceTypeNodePort
+	}
+	if config.ClusterIP != nil {
+		spec.ClusterIP = *config.ClusterIP
+	}
+	if spec.Type == corev1.ServiceTypeClusterIP {
+		for i := range spec.Ports {
+			spec.Ports[i].NodePort = 0
+		}
+	}
+	return spec
+}
+
+// applyObjects creates or updates objects in the order they are given.
+func applyObjects(objs []client.Object, c client.Client, logger logr.Logger) error {
+	for _, obj := range objs {
+		logger.Info("Applying object", "kind", objectKind(obj),
+			"object", obj.GetNamespace()+"/"+obj.GetName())
+		err := applyRuntimeObject(client.ObjectKey{Name: obj.GetName(), Namespace: obj.GetNamespace()}, obj, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func applyRuntimeObject(key client.ObjectKey, obj client.Object, c client.Client) error {
 	ctx := context.Background()
-	switch err := c.Get(ctx, key, obj); {
+	existing, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("unable to copy %s", key)
+	}
+	switch err := c.Get(ctx, key, existing); {
 	case apierror.IsNotFound(err):
 		return c.Create(ctx, obj)
 	case err == nil:
+		if sip, retained := existing.GetAnnotations()[RetainedAnnotation]; retained {
+			return ErrObjectRetained{Object: objectKind(existing) + " " + key.String(), SIPCluster: sip}
+		}
+		// The selector of a Deployment cannot be changed, e.g. when a load balancer switches engines
+		if deployment, ok := obj.(*appsv1.Deployment); ok &&
+			!equality.Semantic.DeepEqual(deployment.Spec.Selector, existing.(*appsv1.Deployment).Spec.Selector) {
+			if err = c.Delete(ctx, existing); err != nil {
+				return err
+			}
+			return c.Create(ctx, obj)
+		}
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		// The cluster IP of a Service is allocated by the API server and cannot be changed
+		if service, ok := obj.(*corev1.Service); ok && service.Spec.ClusterIP == "" {
+			service.Spec.ClusterIP = existing.(*corev1.Service).Spec.ClusterIP
+		}
 		return c.Update(ctx, obj)
 	default:
 		return err
 	}
 }
 
+// deleteObjects deletes objects. An object is done with once its deletion is accepted, even while its own
+// finalizers or a foreground deletion keep it around, since reads through the cache of the manager lag behind the
+// deletion. Objects that do not exist, that are already being deleted, or whose kind is not installed in the cluster,
+// are skipped.
+func deleteObjects(objs []client.Object, c client.Client, logger logr.Logger) error {
+	ctx := context.Background()
+	for _, obj := range objs {
+		if !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		logger.Info("Deleting object", "kind", objectKind(obj), "object", obj.GetNamespace()+"/"+obj.GetName())
+		if err := c.Delete(ctx, obj); err != nil && !apierror.IsNotFound(err) && !apimeta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectKind returns the kind of an object. The kind of typed objects is taken from their type, as it is not always
+// set in them.
+func objectKind(obj client.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.TypeOf(obj).Elem().Name()
+}
+
+// serviceMonitorList returns an empty list of ServiceMonitors.
+func serviceMonitorList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ServiceMonitorGVK.GroupVersion().WithKind(ServiceMonitorGVK.Kind + "List"))
+	return list
+}
+
 func int32Ptr(i int32) *int32 { return &i }
 
 func int64Ptr(i int64) *int64 { return &i }
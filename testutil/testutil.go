@@ -8,6 +8,7 @@ import (
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 
@@ -428,6 +429,52 @@ func CreateBMCAuthSecret(nodeName string, namespace string, username string, pas
 	}
 }
 
+// CreateIPAMAddress creates a Metal3 IPAM IPClaim for a BMH on the given IPPool, and the IPAddress allocated to it, for
+// use in test cases.
+func CreateIPAMAddress(bmhName string, namespace string, pool string, ip string) (*unstructured.Unstructured,
+	*unstructured.Unstructured) {
+	claimName := fmt.Sprintf("%s-%s", bmhName, pool)
+	addressName := fmt.Sprintf("%s-%s", pool, ip)
+	return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "ipam.metal3.io/v1alpha1",
+				"kind":       "IPClaim",
+				"metadata": map[string]interface{}{
+					"name":      claimName,
+					"namespace": namespace,
+				},
+				"spec": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"name": pool,
+					},
+				},
+				"status": map[string]interface{}{
+					"address": map[string]interface{}{
+						"name": addressName,
+					},
+				},
+			},
+		}, &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "ipam.metal3.io/v1alpha1",
+				"kind":       "IPAddress",
+				"metadata": map[string]interface{}{
+					"name":      addressName,
+					"namespace": namespace,
+				},
+				"spec": map[string]interface{}{
+					"address": ip,
+					"claim": map[string]interface{}{
+						"name": claimName,
+					},
+					"pool": map[string]interface{}{
+						"name": pool,
+					},
+				},
+			},
+		}
+}
+
 // CreateTemplateSecret creates a K8s Secret with template for HAProxy configuration
 func CreateTemplateConfigMap(cmname string, templatename string, namespace string,
 	templatedata string) *corev1.ConfigMap {